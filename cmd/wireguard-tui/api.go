@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wireguard-tui/internal/api"
+	"wireguard-tui/internal/audit"
+	"wireguard-tui/internal/backup"
+	"wireguard-tui/internal/helper"
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/settings"
+	"wireguard-tui/internal/state"
+	"wireguard-tui/internal/wg"
+)
+
+// runAPI is `wireguard-tui api`: the operations of the TUI over HTTP for
+// local automation
+func runAPI(args []string) error {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8787", "Loopback host:port or unix:/path to listen on")
+	tokenFile := fs.String("token-file", "", "File holding the bearer token, created if missing (default <state dir>/api.token)")
+	useMock := fs.Bool("mock", false, "Use mock data")
+	helperSocket := fs.String("helper", "", "Talk to a privileged helper on this socket instead of running wg directly")
+	daemonSocket := fs.String("daemon", monitor.DefaultSocket, "Read history from the collector daemon on this socket if it is running")
+	readOnly := fs.Bool("read-only", false, "Only serve reads: refuse every change")
+	policyFile := fs.String("policy", "", "Policy file that further limits what "+policy.DefaultPath+" grants")
+	useSyslog := fs.Bool("syslog", false, "Also send audit records to the local syslog (authpriv)")
+	fs.Parse(args)
+
+	cfg, err := settings.Load()
+	if err != nil {
+		return err
+	}
+	stateDir := state.Dir()
+	var client wg.Client
+	switch {
+	case *helperSocket != "":
+		client = helper.NewClient(*helperSocket)
+	case *useMock:
+		client = wg.NewMockClient()
+		stateDir = filepath.Join(os.TempDir(), "wireguard-tui-mock")
+	default:
+		client = newLinuxClient(cfg)
+	}
+
+	// The system policy always applies; -policy and -read-only narrow it
+	pol, err := policy.LoadDefault(state.Invoker())
+	if err == nil && *policyFile != "" {
+		var extra *policy.Policy
+		extra, err = policy.Load(*policyFile, state.Invoker())
+		pol = pol.Narrow(extra)
+	}
+	if *readOnly {
+		pol = pol.Narrow(policy.ReadOnlyPolicy())
+	}
+	if err != nil {
+		return err
+	}
+	if pol != nil {
+		client = policy.Wrap(client, pol)
+	}
+	logger, err := audit.NewLogger(filepath.Join(stateDir, "audit.log"), *useSyslog)
+	if err != nil {
+		return err
+	}
+	// Changes are made by whatever holds the token, on the authority of
+	// whoever started the API
+	client = audit.WrapAs(client, logger, "api:"+state.Invoker())
+
+	var source monitor.Source
+	if dc := monitor.NewClient(*daemonSocket); !*useMock && dc.Ping() == nil {
+		source = dc
+	} else {
+		c := monitor.NewCollector(client)
+		if cfg.Alerts.StaleAfter > 0 {
+			c.StaleAfter = cfg.Alerts.StaleAfter
+		}
+		go c.Run(context.Background(), 5*time.Second, nil)
+		source = c
+	}
+
+	if *tokenFile == "" {
+		*tokenFile = filepath.Join(stateDir, "api.token")
+	}
+	token, err := api.LoadToken(*tokenFile)
+	if err != nil {
+		return fmt.Errorf("cannot load token: %v", err)
+	}
+
+	l, err := api.Listen(*listen)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", *listen, err)
+	}
+	s := &api.Server{
+		Client:  client,
+		Source:  source,
+		Backups: backup.NewStore(filepath.Join(stateDir, "backups")),
+		Token:   token,
+	}
+	log.Printf("API listening on %s, token in %s", *listen, *tokenFile)
+	return http.Serve(l, s.Handler())
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"wireguard-tui/internal/helper"
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/settings"
+	"wireguard-tui/internal/ui"
+	"wireguard-tui/internal/web"
+	"wireguard-tui/internal/wg"
+)
+
+// runDaemon is `wireguard-tui daemon`: it keeps collecting history and
+// events while no TUI is open and serves them to the TUI when one is
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := fs.String("socket", monitor.DefaultSocket, "Unix socket to listen on")
+	interval := fs.Duration("interval", 5*time.Second, "How often to poll WireGuard")
+	retention := fs.Duration("retention", 24*time.Hour, "How long to keep history and events")
+	uid := fs.Int("uid", -1, "User allowed to connect besides root (default $SUDO_UID)")
+	useMock := fs.Bool("mock", false, "Collect mock data")
+	webAddr := fs.String("web", "", "Also serve a read-only web dashboard on this address, e.g. :8080")
+	fs.Parse(args)
+
+	if *uid < 0 {
+		if id, err := strconv.Atoi(os.Getenv("SUDO_UID")); err == nil {
+			*uid = id
+		}
+	}
+
+	cfg, err := settings.Load()
+	if err != nil {
+		return err
+	}
+	var client wg.Client = newLinuxClient(cfg)
+	if *useMock {
+		client = wg.NewMockClient()
+	}
+	c := monitor.NewCollector(client)
+	c.Resolution = *interval
+	c.Retention = *retention
+	if cfg.Alerts.StaleAfter > 0 {
+		c.StaleAfter = cfg.Alerts.StaleAfter
+	}
+	alerts := monitor.Alerts(cfg.Alerts.Events)
+
+	if *webAddr != "" {
+		themes, err := ui.LoadThemes(settings.ThemeDirs()...)
+		if err != nil {
+			return err
+		}
+		if err := (&web.Server{Source: c, Themes: themes, Theme: cfg.Theme}).Serve(*webAddr); err != nil {
+			return err
+		}
+	}
+
+	l, err := helper.Listen(*socket, *uid)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", *socket, err)
+	}
+	defer l.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, func() { l.Close() })
+
+	go c.Run(ctx, *interval, func(events []monitor.Event, err error) {
+		if err != nil {
+			log.Printf("poll failed: %v", err)
+		}
+		for _, e := range events {
+			level := "event"
+			if alerts.Match(e) {
+				level = "ALERT"
+			}
+			log.Printf("%s: %s", level, e)
+		}
+	})
+
+	log.Printf("daemon listening on %s, polling every %s", *socket, *interval)
+	err = monitor.NewServer(c, *uid).Serve(l)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"wireguard-tui/internal/doctor"
+	"wireguard-tui/internal/helper"
+	"wireguard-tui/internal/settings"
+	"wireguard-tui/internal/wg"
+)
+
+// runDoctor is `wireguard-tui doctor`: it checks that a machine is ready
+// for WireGuard and exits with an error if something is not
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	host := fs.String("host", "", "Check this host over SSH instead of this machine")
+	helperSocket := fs.String("helper", "", "Read interfaces through a privileged helper on this socket")
+	useMock := fs.Bool("mock", false, "Use mock interfaces")
+	fs.Parse(args)
+
+	cfg, err := settings.Load()
+	if err != nil {
+		return err
+	}
+	env := doctor.Env{ConfigGlobs: cfg.ConfigPaths}
+	switch {
+	case *host != "":
+		remote := wg.NewSSHClient(*host)
+		remote.ConfigGlobs = cfg.ConfigPaths
+		defer remote.Runner.(*wg.SSHRunner).Close()
+		env.Client, env.Runner = remote, remote.Runner
+	case *helperSocket != "":
+		env.Client = helper.NewClient(*helperSocket)
+	case *useMock:
+		env.Client = wg.NewMockClient()
+	case os.Geteuid() != 0 && fileExists(helper.DefaultSocket):
+		env.Client = helper.NewClient(helper.DefaultSocket)
+	default:
+		env.Client = newLinuxClient(cfg)
+	}
+
+	checks := env.Run(context.Background())
+	width := 0
+	for _, c := range checks {
+		width = max(width, len(c.Name))
+	}
+	for _, c := range checks {
+		fmt.Printf("%s  %-*s  %s\n", c.Status, width, c.Name, c.Detail)
+		if c.Hint != "" {
+			fmt.Printf("%s  %-*s  → %s\n", strings.Repeat(" ", 4), width, "", c.Hint)
+		}
+	}
+	if n := doctor.Failed(checks); n > 0 {
+		return fmt.Errorf("%d of %d checks failed", n, len(checks))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"wireguard-tui/internal/audit"
+	"wireguard-tui/internal/helper"
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/settings"
+	"wireguard-tui/internal/state"
+	"wireguard-tui/internal/wg"
+)
+
+// runHelper is `wireguard-tui helper`: the privileged half, which the TUI
+// talks to over a Unix socket
+func runHelper(args []string) error {
+	fs := flag.NewFlagSet("helper", flag.ExitOnError)
+	socket := fs.String("socket", helper.DefaultSocket, "Unix socket to listen on")
+	uid := fs.Int("uid", -1, "User allowed to connect (default $SUDO_UID)")
+	parent := fs.Int("parent", 0, "Exit when this process exits")
+	useMock := fs.Bool("mock", false, "Serve mock data")
+	fs.Parse(args)
+
+	if *uid < 0 {
+		id, err := strconv.Atoi(os.Getenv("SUDO_UID"))
+		if err != nil {
+			return fmt.Errorf("-uid is required when not started through sudo")
+		}
+		*uid = id
+	}
+
+	// The helper acts for another user, whose files have no say in what
+	// it runs as root
+	cfg, err := settings.LoadSystem()
+	if err != nil {
+		return err
+	}
+	var client wg.Client = newLinuxClient(cfg)
+	stateDir := state.Dir()
+	if *useMock {
+		client = wg.NewMockClient()
+		stateDir = filepath.Join(os.TempDir(), "wireguard-tui-mock")
+	}
+	// The log is root's, out of reach of the users it records
+	logger, err := audit.NewLogger(filepath.Join(stateDir, "audit.log"), false)
+	if err != nil {
+		return err
+	}
+
+	l, err := helper.Listen(*socket, *uid)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", *socket, err)
+	}
+	defer l.Close()
+
+	if *parent > 0 {
+		go func() {
+			for syscall.Kill(*parent, 0) == nil {
+				time.Sleep(2 * time.Second)
+			}
+			l.Close()
+		}()
+	}
+
+	log.Printf("helper listening on %s for uid %d", *socket, *uid)
+	srv := helper.NewServer(client, *uid)
+	// Whatever the UI enforces can be skipped by talking to the socket
+	// directly, so the policy applies here, to whoever the kernel says
+	// is connected
+	srv.ClientFor = func(uid int) (wg.Client, error) {
+		name := strconv.Itoa(uid)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		pol, err := policy.LoadDefault(name)
+		if err != nil {
+			return nil, err
+		}
+		c := client
+		if pol != nil {
+			c = policy.Wrap(c, pol)
+		}
+		return audit.WrapAs(c, logger, name), nil
+	}
+	if err := srv.Serve(l); err != nil && *parent == 0 {
+		return err
+	}
+	return nil
+}
+
+// startSudoHelper launches a helper through sudo that lives as long as we
+// do. sudo asks for a password before the UI takes over the terminal.
+func startSudoHelper(useMock bool) (*helper.Client, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "wireguard-tui-")
+	if err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dir, "helper.sock")
+
+	validate := exec.Command("sudo", "-v")
+	validate.Stdin, validate.Stdout, validate.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := validate.Run(); err != nil {
+		return nil, fmt.Errorf("sudo failed: %v", err)
+	}
+
+	args := []string{"-n", "-b", exe, "helper",
+		"-socket", socket,
+		"-uid", strconv.Itoa(os.Getuid()),
+		"-parent", strconv.Itoa(os.Getpid()),
+	}
+	if useMock {
+		args = append(args, "-mock")
+	}
+	if err := exec.Command("sudo", args...).Run(); err != nil {
+		return nil, fmt.Errorf("cannot start helper: %v", err)
+	}
+
+	client := helper.NewClient(socket)
+	for i := 0; i < 50; i++ {
+		if err = client.Ping(); err == nil {
+			return client, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil, err
+}
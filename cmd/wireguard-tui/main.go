@@ -1,32 +1,270 @@
 package main
 
 import (
+	"cmp"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"wireguard-tui/internal/audit"
+	"wireguard-tui/internal/helper"
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/settings"
+	"wireguard-tui/internal/state"
 	"wireguard-tui/internal/ui"
+	"wireguard-tui/internal/web"
 	"wireguard-tui/internal/wg"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 func main() {
+	subcommands := map[string]func([]string) error{
+		"helper": runHelper,
+		"daemon": runDaemon,
+		"api":    runAPI,
+		"doctor": runDoctor,
+	}
+	if len(os.Args) > 1 && subcommands[os.Args[1]] != nil {
+		if err := subcommands[os.Args[1]](os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Parse flags
 	useMock := flag.Bool("mock", false, "Use mock data (for development/demo)")
+	rotationWindow := flag.Duration("rotation-window", 2*time.Minute, "How long to wait for handshakes after a key rotation before offering rollback")
+	safetyTimeout := flag.Duration("safety-timeout", time.Minute, "Default delay before an interface taken down despite a lockout warning comes back up")
+	useSyslog := flag.Bool("syslog", false, "Also send audit records to the local syslog (authpriv)")
+	readOnly := flag.Bool("read-only", false, "Only watch: refuse every change")
+	policyFile := flag.String("policy", "", "Policy file that further limits what "+policy.DefaultPath+" grants")
+	helperSocket := flag.String("helper", "", "Talk to a privileged helper on this socket instead of running wg directly")
+	sudoHelper := flag.Bool("sudo-helper", false, "Start a privileged helper through sudo and run the UI as the current user")
+	webAddr := flag.String("web", "", "Also serve a read-only web dashboard on this address, e.g. :8080")
+	hostList := flag.String("hosts", "", "Comma-separated hosts to manage over SSH, switched with Tab; \"local\" is this machine, e.g. local,gw1,admin@gw2")
+	daemonSocket := flag.String("daemon", "", "Attach to the collector daemon on this socket (default "+monitor.DefaultSocket+" if running, \"off\" to poll directly)")
+	configFile := flag.String("config", "", "Settings file (default "+settings.SystemPath+" and ~/.config/wireguard-tui/config.toml)")
+	refresh := flag.Duration("refresh", time.Second, "How often to poll the interfaces")
+	themeName := flag.String("theme", "", "Theme to start with, e.g. Nord")
+	plain := flag.Bool("plain", false, "Lay the screen out as plain lines of text for screen readers")
 	flag.Parse()
 
+	cfg, err := loadSettings(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// The settings file fills in what the command line leaves out
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["refresh"] && cfg.Refresh > 0 {
+		*refresh = cfg.Refresh
+	}
+	if !set["theme"] {
+		*themeName = cfg.Theme
+	}
+	if !set["plain"] {
+		*plain = cfg.Plain
+	}
+	if !set["daemon"] && cfg.DaemonSocket != "" {
+		*daemonSocket = cfg.DaemonSocket
+	}
+	backend := cfg.Backend
+	if set["mock"] || set["helper"] || set["sudo-helper"] {
+		backend = ""
+	}
+	switch backend {
+	case "mock":
+		*useMock = true
+	case "sudo-helper":
+		*sudoHelper = true
+	case "helper":
+		*helperSocket = cmp.Or(cfg.HelperSocket, helper.DefaultSocket)
+	}
+	systemHelper := cmp.Or(cfg.HelperSocket, helper.DefaultSocket)
+	themes, err := ui.LoadThemes(settings.ThemeDirs()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, ok := ui.ThemeIndex(themes, *themeName); !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown theme %q\n", *themeName)
+		os.Exit(1)
+	}
+	for _, key := range cfg.Columns {
+		if !slices.Contains(ui.ColumnKeys(), key) {
+			fmt.Fprintf(os.Stderr, "Error: unknown column %q, expected one of %s\n", key, strings.Join(ui.ColumnKeys(), ", "))
+			os.Exit(1)
+		}
+	}
+
 	var client wg.Client
-	if *useMock {
+	opts := ui.Options{
+		RotationWindow: *rotationWindow, SafetyTimeout: *safetyTimeout, StateDir: state.Dir(),
+		Refresh: *refresh, Themes: themes, ThemeDirs: settings.ThemeDirs(), Theme: *themeName,
+		Columns: cfg.Columns, ColumnWidths: cfg.ColumnWidths, Plain: *plain, NoColor: honorNoColor(),
+		Units: cfg.Units, Alerts: cfg.Alerts.Events, StaleAfter: cfg.Alerts.StaleAfter,
+	}
+	switch {
+	case *sudoHelper:
+		hc, err := startSudoHelper(*useMock)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		client = hc
+	case *helperSocket != "":
+		client = helper.NewClient(*helperSocket)
+	case *useMock:
 		client = wg.NewMockClient()
-	} else {
-		client = wg.NewLinuxClient()
+	case backend != "local" && os.Geteuid() != 0 && fileExists(systemHelper):
+		// A system-wide helper lets us run without sudo
+		client = helper.NewClient(systemHelper)
+	default:
+		client = newLinuxClient(cfg)
+	}
+	if *useMock {
+		// Keep demo state away from the real one
+		opts.StateDir = filepath.Join(os.TempDir(), "wireguard-tui-mock")
+	}
+
+	// Mock data and a real daemon do not mix unless asked for
+	if *daemonSocket == "" && !*useMock {
+		*daemonSocket = monitor.DefaultSocket
+	}
+	if *daemonSocket != "" && *daemonSocket != "off" {
+		if dc := monitor.NewClient(*daemonSocket); dc.Ping() == nil {
+			opts.Daemon = dc
+		}
 	}
 
-	m := ui.NewModel(client)
+	// The system policy always applies; -policy and -read-only narrow it
+	pol, err := policy.LoadDefault(state.Invoker())
+	if err == nil && *policyFile != "" {
+		var extra *policy.Policy
+		extra, err = policy.Load(*policyFile, state.Invoker())
+		pol = pol.Narrow(extra)
+	}
+	if *readOnly {
+		pol = pol.Narrow(policy.ReadOnlyPolicy())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.Policy = pol
+	logger, err := audit.NewLogger(filepath.Join(opts.StateDir, "audit.log"), *useSyslog)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.Audit = logger
+	// wrap applies the policy and records changes, on every host alike
+	wrap := func(c wg.Client) *audit.Client {
+		if pol != nil {
+			c = policy.Wrap(c, pol)
+		}
+		return audit.Wrap(c, logger)
+	}
+	client = wrap(client)
+
+	// The UI shows why the dashboard stopped, as logging it would draw
+	// over the screen
+	var dashStopped chan error
+	if *webAddr != "" {
+		// Show what the UI shows: the daemon's data if attached, else
+		// what the UI polls
+		opts.Collector = monitor.NewCollector(client)
+		if cfg.Alerts.StaleAfter > 0 {
+			opts.Collector.StaleAfter = cfg.Alerts.StaleAfter
+		}
+		dash := &web.Server{Source: opts.Collector, Themes: themes, Theme: *themeName}
+		dashStopped = make(chan error, 1)
+		dash.Stopped = func(err error) { dashStopped <- err }
+		if opts.Daemon != nil {
+			dash.Source = opts.Daemon
+		}
+		if err := dash.Serve(*webAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *hostList != "" {
+		for _, name := range strings.Split(*hostList, ",") {
+			name = strings.TrimSpace(name)
+			switch {
+			case name == "":
+				continue
+			case name == "local":
+				opts.Hosts = append(opts.Hosts, ui.Host{Name: name, Client: client, Daemon: opts.Daemon, Collector: opts.Collector})
+				continue
+			case *useMock:
+				opts.Hosts = append(opts.Hosts, ui.Host{Name: name, Client: wrap(wg.NewMockClient()).OnHost(name)})
+				continue
+			}
+			remote := wg.NewSSHClient(name)
+			remote.ConfigGlobs = cfg.ConfigPaths
+			defer remote.Runner.(*wg.SSHRunner).Close()
+			opts.Hosts = append(opts.Hosts, ui.Host{Name: name, Client: wrap(remote).OnHost(name), Runner: remote.Runner})
+		}
+		if len(opts.Hosts) > 0 {
+			client = opts.Hosts[0].Client
+		}
+	}
+
+	m := ui.NewModel(client, opts)
 	p := tea.NewProgram(m, tea.WithAltScreen())
+	if dashStopped != nil {
+		go func() {
+			p.Send(ui.ErrorMsg{Err: fmt.Errorf("web dashboard stopped: %v", <-dashStopped)})
+		}()
+	}
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error starting program: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// honorNoColor says whether NO_COLOR is set. Lipgloss then drops bold and
+// reverse video as well, which the monochrome theme needs to show the
+// selection, so they are brought back on terminals that have them.
+func honorNoColor() bool {
+	if os.Getenv("NO_COLOR") == "" {
+		return false
+	}
+	if termenv.NewOutput(os.Stdout).ColorProfile() != termenv.Ascii {
+		lipgloss.SetColorProfile(termenv.ANSI)
+	}
+	return true
+}
+
+// loadSettings reads the given settings file, or the default ones
+func loadSettings(path string) (*settings.Settings, error) {
+	if path != "" {
+		return settings.LoadFile(path)
+	}
+	return settings.Load()
+}
+
+// newLinuxClient manages this machine, looking for configs where the
+// settings say
+func newLinuxClient(cfg *settings.Settings) *wg.LinuxClient {
+	c := wg.NewLinuxClient()
+	c.ConfigGlobs = cfg.ConfigPaths
+	return c
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,480 @@
+// Package api offers the operations of the TUI as a small HTTP/JSON API for
+// local automation. It is opt-in, only listens on loopback or a Unix
+// socket, and every request needs the bearer token.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"wireguard-tui/internal/backup"
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/wg"
+)
+
+// Server serves the API. Client is expected to enforce the policy and
+// audit changes, like the one the TUI uses.
+type Server struct {
+	Client  wg.Client
+	Source  monitor.Source
+	Backups *backup.Store
+	Token   string
+}
+
+// Handler returns the routes behind token authentication
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /interfaces", s.listInterfaces)
+	mux.HandleFunc("GET /interfaces/{name}", s.getInterface)
+	mux.HandleFunc("GET /interfaces/{name}/peers", s.listPeers)
+	mux.HandleFunc("GET /interfaces/{name}/history", s.getHistory)
+	mux.HandleFunc("GET /events", s.listEvents)
+	mux.HandleFunc("POST /interfaces/{name}/{action}", s.act)
+	mux.HandleFunc("PUT /interfaces/{name}/peers/{key}", s.putPeer)
+	mux.HandleFunc("DELETE /interfaces/{name}/peers/{key}", s.deletePeer)
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Interface is how interfaces are shown
+type Interface struct {
+	Name       string `json:"name"`
+	PublicKey  string `json:"public_key"`
+	ListenPort int    `json:"listen_port"`
+	FwMark     int    `json:"fwmark"`
+	Up         bool   `json:"up"`
+	// Status is "up", "down" or "unknown" when wg could not be read
+	Status string `json:"status"`
+	Peers  []Peer `json:"peers"`
+}
+
+// Peer is how peers are shown
+type Peer struct {
+	PublicKey           string     `json:"public_key"`
+	Endpoint            string     `json:"endpoint,omitempty"`
+	AllowedIPs          []string   `json:"allowed_ips"`
+	LatestHandshake     *time.Time `json:"latest_handshake"`
+	TransferRx          int64      `json:"transfer_rx"`
+	TransferTx          int64      `json:"transfer_tx"`
+	PersistentKeepalive int        `json:"persistent_keepalive,omitempty"`
+}
+
+func newInterface(iface wg.Interface, peers []wg.Peer) Interface {
+	out := Interface{
+		Name:       iface.Name,
+		PublicKey:  iface.PublicKey,
+		ListenPort: iface.ListenPort,
+		FwMark:     iface.FirewallMark,
+		Up:         iface.Status == wg.InterfaceUp,
+		Status:     strings.ToLower(iface.Status.String()),
+		Peers:      []Peer{},
+	}
+	for _, p := range peers {
+		out.Peers = append(out.Peers, newPeer(p))
+	}
+	return out
+}
+
+func newPeer(p wg.Peer) Peer {
+	out := Peer{
+		PublicKey:           p.PublicKey,
+		Endpoint:            p.Endpoint,
+		AllowedIPs:          p.AllowedIPs,
+		TransferRx:          p.TransferRx,
+		TransferTx:          p.TransferTx,
+		PersistentKeepalive: p.PersistentKeepalive,
+	}
+	if out.AllowedIPs == nil {
+		out.AllowedIPs = []string{}
+	}
+	if !p.LatestHandshake.IsZero() {
+		t := p.LatestHandshake
+		out.LatestHandshake = &t
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := map[string]string{"error": err.Error()}
+	if e := wg.Cause(err); e != nil {
+		body["kind"] = string(e.Kind)
+		body["hint"] = e.Explain() + "; " + e.Fix()
+	}
+	writeJSON(w, status, body)
+}
+
+// fail picks a status code for an error from the client
+func fail(w http.ResponseWriter, err error) {
+	var denied *policy.DeniedError
+	switch {
+	case errors.As(err, &denied):
+		writeError(w, http.StatusForbidden, err)
+	case errors.Is(err, os.ErrNotExist):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func (s *Server) snapshot(w http.ResponseWriter, r *http.Request, points, events int) (*monitor.Snapshot, bool) {
+	snap, err := s.Source.Snapshot(r.Context(), true, points, events)
+	if err != nil {
+		fail(w, err)
+		return nil, false
+	}
+	return snap, true
+}
+
+// known refuses to change an interface whose state could not be read
+func known(w http.ResponseWriter, iface wg.Interface) bool {
+	if iface.Status == wg.InterfaceUnknown {
+		writeError(w, http.StatusConflict, fmt.Errorf("state of %s is unknown", iface.Name))
+		return false
+	}
+	return true
+}
+
+// find looks up the interface named in the path
+func (s *Server) find(w http.ResponseWriter, r *http.Request, snap *monitor.Snapshot) (wg.Interface, bool) {
+	name := r.PathValue("name")
+	for _, iface := range snap.Interfaces {
+		if iface.Name == name {
+			return iface, true
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("no interface %q", name))
+	return wg.Interface{}, false
+}
+
+func (s *Server) listInterfaces(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r, 0, 0)
+	if !ok {
+		return
+	}
+	out := []Interface{}
+	for _, iface := range snap.Interfaces {
+		out = append(out, newInterface(iface, snap.Peers[iface.Name]))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getInterface(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r, 0, 0)
+	if !ok {
+		return
+	}
+	if iface, ok := s.find(w, r, snap); ok {
+		writeJSON(w, http.StatusOK, newInterface(iface, snap.Peers[iface.Name]))
+	}
+}
+
+func (s *Server) listPeers(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r, 0, 0)
+	if !ok {
+		return
+	}
+	if iface, ok := s.find(w, r, snap); ok {
+		writeJSON(w, http.StatusOK, newInterface(iface, snap.Peers[iface.Name]).Peers)
+	}
+}
+
+// limit reads a positive count from the query, as in ?limit=100
+func limit(r *http.Request, def int) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r, limit(r, 720), 0)
+	if !ok {
+		return
+	}
+	if iface, ok := s.find(w, r, snap); ok {
+		points := snap.History[iface.Name]
+		if points == nil {
+			points = []monitor.Point{}
+		}
+		writeJSON(w, http.StatusOK, points)
+	}
+}
+
+func (s *Server) listEvents(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r, 0, limit(r, 100))
+	if !ok {
+		return
+	}
+	events := []monitor.Event{}
+	for _, e := range snap.Events {
+		if name := r.URL.Query().Get("interface"); name == "" || e.Interface == name {
+			events = append(events, e)
+		}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// act runs POST /interfaces/{name}/up, down, restart or reload. Taking
+// down the interface that carries the default route needs ?force=1,
+// since nobody is there to type its name.
+func (s *Server) act(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r, 0, 0)
+	if !ok {
+		return
+	}
+	iface, ok := s.find(w, r, snap)
+	if !ok || !known(w, iface) {
+		return
+	}
+	name := iface.Name
+	action := r.PathValue("action")
+	if action == "down" || action == "restart" {
+		if ri, ok := wg.As[wg.RouteInspector](s.Client); ok && r.URL.Query().Get("force") == "" {
+			if risks := wg.LockoutRisks(r.Context(), ri, name); len(risks) > 0 {
+				writeError(w, http.StatusConflict, fmt.Errorf("%s, add ?force=1 to take it down anyway", strings.Join(risks, "; ")))
+				return
+			}
+		}
+	}
+
+	var out strings.Builder
+	var err error
+	switch action {
+	case "up":
+		err = s.Client.ToggleInterface(r.Context(), name, true, &out)
+	case "down":
+		err = s.Client.ToggleInterface(r.Context(), name, false, &out)
+	case "restart":
+		// A client hanging up between down and up must not leave the
+		// interface down
+		ctx := context.WithoutCancel(r.Context())
+		if iface.Status == wg.InterfaceUp {
+			err = s.Client.ToggleInterface(ctx, name, false, &out)
+		}
+		if err == nil {
+			err = s.Client.ToggleInterface(ctx, name, true, &out)
+		}
+	case "reload":
+		err = s.Client.SyncConfig(r.Context(), name)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown action %q", action))
+		return
+	}
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"output": out.String()})
+}
+
+// PeerConfig is the body of PUT /interfaces/{name}/peers/{key}
+type PeerConfig struct {
+	AllowedIPs          []string `json:"allowed_ips"`
+	Endpoint            string   `json:"endpoint,omitempty"`
+	PersistentKeepalive int      `json:"persistent_keepalive,omitempty"`
+	PresharedKey        string   `json:"preshared_key,omitempty"`
+}
+
+func (p PeerConfig) entries() []wg.Entry {
+	entries := []wg.Entry{{Key: "AllowedIPs", Value: strings.Join(p.AllowedIPs, ", ")}}
+	if p.PresharedKey != "" {
+		entries = append(entries, wg.Entry{Key: "PresharedKey", Value: p.PresharedKey})
+	}
+	if p.Endpoint != "" {
+		entries = append(entries, wg.Entry{Key: "Endpoint", Value: p.Endpoint})
+	}
+	if p.PersistentKeepalive > 0 {
+		entries = append(entries, wg.Entry{Key: "PersistentKeepalive", Value: strconv.Itoa(p.PersistentKeepalive)})
+	}
+	return entries
+}
+
+// peerKey reads the key from the path. Standard base64 needs its slashes
+// escaped there, so the URL-safe alphabet is accepted too.
+func peerKey(r *http.Request) (string, error) {
+	key := strings.NewReplacer("-", "+", "_", "/").Replace(r.PathValue("key"))
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 32 {
+		return "", fmt.Errorf("invalid public key %q", key)
+	}
+	return key, nil
+}
+
+func (s *Server) putPeer(w http.ResponseWriter, r *http.Request) {
+	key, err := peerKey(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var pc PeerConfig
+	dec := json.NewDecoder(io.LimitReader(r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&pc); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %v", err))
+		return
+	}
+	if len(pc.AllowedIPs) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("allowed_ips is required"))
+		return
+	}
+	s.changePeers(w, r, "api: set peer", func(data []byte) ([]byte, error) {
+		return wg.SetPeer(data, key, pc.entries())
+	})
+}
+
+func (s *Server) deletePeer(w http.ResponseWriter, r *http.Request) {
+	key, err := peerKey(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.changePeers(w, r, "api: remove peer", func(data []byte) ([]byte, error) {
+		return wg.RemovePeer(data, key)
+	})
+}
+
+// changePeers rewrites the config of the interface in the path, after
+// backing it up, and applies it if the interface is running. If applying
+// fails, the old config is written back.
+func (s *Server) changePeers(w http.ResponseWriter, r *http.Request, action string, change func([]byte) ([]byte, error)) {
+	snap, ok := s.snapshot(w, r, 0, 0)
+	if !ok {
+		return
+	}
+	iface, ok := s.find(w, r, snap)
+	if !ok || !known(w, iface) {
+		return
+	}
+	name := iface.Name
+
+	old, err := s.Client.ReadConfig(r.Context(), name)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	data, err := change(old)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cfg, err := wg.ParseConfig(data)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid config: %v", err))
+		return
+	}
+
+	if _, err := s.Backups.Snapshot(name, action, old); err != nil {
+		fail(w, fmt.Errorf("backup failed, not writing %s: %v", iface.ConfigPath(), err))
+		return
+	}
+	if err := s.Client.WriteConfig(r.Context(), name, data); err != nil {
+		fail(w, err)
+		return
+	}
+	if iface.Status == wg.InterfaceUp {
+		if err := s.Client.SyncConfig(r.Context(), name); err != nil {
+			// Roll back even if the caller has gone away by now
+			if rbErr := s.Client.WriteConfig(context.WithoutCancel(r.Context()), name, old); rbErr != nil {
+				err = fmt.Errorf("%v (rollback also failed: %v)", err, rbErr)
+			}
+			fail(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"applied": iface.Status == wg.InterfaceUp})
+}
+
+// Listen opens addr, which is either unix:/path or a loopback host:port.
+// Anything reachable from other machines is refused.
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			l.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("%s is not a loopback address", host)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+// LoadToken reads the token from path, creating a random one there if
+// the file does not exist yet
+func LoadToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
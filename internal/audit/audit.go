@@ -0,0 +1,146 @@
+// Package audit records every change made to WireGuard through the app,
+// so on a shared gateway it is always clear who did what and whether it
+// worked.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one line of the audit log
+type Record struct {
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	// Host is the remote machine the change was made on, if any
+	Host      string `json:"host,omitempty"`
+	Interface string `json:"interface"`
+	Action    string `json:"action"`
+	// Params never contain private or preshared keys
+	Params map[string]any `json:"params,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// OK reports whether the action succeeded
+func (r Record) OK() bool {
+	return r.Error == ""
+}
+
+// Logger appends records to a JSON lines file and optionally forwards a
+// one-line summary of each to the local syslog daemon
+type Logger struct {
+	Path string
+
+	mu     sync.Mutex
+	syslog *syslog.Writer
+}
+
+// NewLogger logs to path. With useSyslog it also logs to the authpriv
+// facility, which most distributions keep out of world-readable files.
+func NewLogger(path string, useSyslog bool) (*Logger, error) {
+	l := &Logger{Path: path}
+	if useSyslog {
+		w, err := syslog.New(syslog.LOG_AUTHPRIV|syslog.LOG_NOTICE, "wireguard-tui")
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to syslog: %v", err)
+		}
+		l.syslog = w
+	}
+	return l, nil
+}
+
+// Log appends r. The file is only ever opened for appending.
+func (l *Logger) Log(r Record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log dir: %v", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+
+	if l.syslog != nil {
+		msg := fmt.Sprintf("user=%s interface=%s action=%q", r.User, r.Interface, r.Action)
+		if r.Host != "" {
+			msg = "host=" + r.Host + " " + msg
+		}
+		if r.OK() {
+			l.syslog.Notice(msg + " outcome=ok")
+		} else {
+			l.syslog.Err(msg + fmt.Sprintf(" outcome=error error=%q", r.Error))
+		}
+	}
+	return nil
+}
+
+// Read returns up to limit of the most recent records, newest first.
+// Lines that do not parse are skipped rather than hiding the rest.
+func (l *Logger) Read(limit int) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var r Record
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		records = append(records, r)
+		if len(records) > limit {
+			records = records[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// secretKeys are config keys whose values never reach the log
+var secretKeys = map[string]bool{
+	"privatekey":   true,
+	"presharedkey": true,
+}
+
+// Redact masks the value of secret keys in a line of a wg-quick config
+func Redact(line string) string {
+	key, _, ok := strings.Cut(line, "=")
+	if ok && secretKeys[strings.ToLower(strings.TrimSpace(key))] {
+		return strings.TrimRight(key, " ") + " = [redacted]"
+	}
+	return line
+}
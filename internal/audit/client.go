@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"wireguard-tui/internal/diff"
+	"wireguard-tui/internal/state"
+	"wireguard-tui/internal/wg"
+)
+
+// Client wraps a wg.Client and logs every call that changes something.
+// Reads pass straight through.
+type Client struct {
+	wg.Client
+	log  *Logger
+	user string
+	host string
+}
+
+// Wrap returns a client that records the changes made through c to log
+func Wrap(c wg.Client, log *Logger) *Client {
+	return WrapAs(c, log, state.Invoker())
+}
+
+// WrapAs is Wrap for changes made on behalf of someone other than the
+// person running the app
+func WrapAs(c wg.Client, log *Logger, user string) *Client {
+	return &Client{Client: c, log: log, user: user}
+}
+
+// Unwrap returns the client whose changes are recorded
+func (c *Client) Unwrap() wg.Client {
+	return c.Client
+}
+
+// OnHost marks the changes as made on a remote host
+func (c *Client) OnHost(host string) *Client {
+	c.host = host
+	return c
+}
+
+// record logs the outcome of an action. An action that succeeded but could
+// not be logged is reported as failed, so it does not go unnoticed.
+func (c *Client) record(iface, action string, params map[string]any, err error) error {
+	r := Record{
+		Time:      time.Now(),
+		User:      c.user,
+		Host:      c.host,
+		Interface: iface,
+		Action:    action,
+		Params:    params,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if logErr := c.log.Log(r); logErr != nil && err == nil {
+		return fmt.Errorf("%s done, but not recorded: %v", action, logErr)
+	}
+	return err
+}
+
+func (c *Client) ToggleInterface(ctx context.Context, name string, up bool, out io.Writer) error {
+	action := "down"
+	if up {
+		action = "up"
+	}
+	return c.record(name, action, nil, c.Client.ToggleInterface(ctx, name, up, out))
+}
+
+func (c *Client) WriteConfig(ctx context.Context, name string, data []byte) error {
+	params := map[string]any{"size": len(data)}
+	if old, err := c.Client.ReadConfig(ctx, name); err == nil {
+		var changes []string
+		for _, l := range diff.Text(string(old), string(data)) {
+			switch l.Op {
+			case diff.Insert:
+				changes = append(changes, "+ "+Redact(l.Text))
+			case diff.Delete:
+				changes = append(changes, "- "+Redact(l.Text))
+			}
+		}
+		params["changes"] = changes
+	}
+	return c.record(name, "write config", params, c.Client.WriteConfig(ctx, name, data))
+}
+
+func (c *Client) SyncConfig(ctx context.Context, name string) error {
+	return c.record(name, "syncconf", nil, c.Client.SyncConfig(ctx, name))
+}
+
+func (c *Client) SetPrivateKey(ctx context.Context, name string, privateKey string) error {
+	// The public half identifies the new key without revealing it
+	var params map[string]any
+	if pub, err := wg.PublicKey(privateKey); err == nil {
+		params = map[string]any{"public_key": pub}
+	}
+	return c.record(name, "set private key", params, c.Client.SetPrivateKey(ctx, name, privateKey))
+}
+
+// RouteDevice passes through; the wrapper has to implement it for the
+// lockout guard to still find it
+func (c *Client) RouteDevice(ctx context.Context, dst netip.Addr) (string, error) {
+	ri, ok := wg.As[wg.RouteInspector](c.Client)
+	if !ok {
+		return "", fmt.Errorf("route lookup not supported")
+	}
+	return ri.RouteDevice(ctx, dst)
+}
+
+// UDPSockets passes through, like RouteDevice
+func (c *Client) UDPSockets(ctx context.Context, ports []int) ([]wg.UDPSocket, error) {
+	pi, ok := wg.As[wg.PortInspector](c.Client)
+	if !ok {
+		return nil, fmt.Errorf("socket listing not supported")
+	}
+	return pi.UDPSockets(ctx, ports)
+}
+
+func (c *Client) ArmSafetyTimer(ctx context.Context, name string, after time.Duration) (func() error, error) {
+	st, ok := wg.As[wg.SafetyTimer](c.Client)
+	if !ok {
+		return nil, fmt.Errorf("safety timer not supported")
+	}
+	params := map[string]any{"after": after.String()}
+	cancel, err := st.ArmSafetyTimer(ctx, name, after)
+	if err != nil {
+		return nil, c.record(name, "arm safety timer", params, err)
+	}
+	if err := c.record(name, "arm safety timer", params, nil); err != nil {
+		// The interface does not go down on an error, so the timer is
+		// not needed; only one we cannot stop is handed back
+		if cancelErr := cancel(); cancelErr != nil {
+			return cancel, fmt.Errorf("%v, and disarming failed: %v", err, cancelErr)
+		}
+		return nil, err
+	}
+	return func() error {
+		return c.record(name, "disarm safety timer", nil, cancel())
+	}, nil
+}
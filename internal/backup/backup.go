@@ -0,0 +1,111 @@
+// Package backup keeps versioned snapshots of WireGuard config files so a
+// change made through the TUI can always be undone.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wireguard-tui/internal/state"
+	"wireguard-tui/internal/wg"
+)
+
+// idLayout sorts lexically in time order and is safe in file names
+const idLayout = "20060102T150405.000000000Z"
+
+// Version describes one snapshot of an interface config
+type Version struct {
+	ID        string    `json:"id"`
+	Interface string    `json:"interface"`
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Action    string    `json:"action"`
+	Size      int       `json:"size"`
+}
+
+// Store keeps snapshots in Dir/<interface>/<id>.conf, with the metadata
+// next to each one in <id>.json
+type Store struct {
+	Dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Snapshot saves data as the version of iface that existed before action
+func (s *Store) Snapshot(iface, action string, data []byte) (Version, error) {
+	if !wg.ValidInterfaceName(iface) {
+		return Version{}, fmt.Errorf("invalid interface name %q", iface)
+	}
+	dir := filepath.Join(s.Dir, iface)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Version{}, fmt.Errorf("failed to create backup dir: %v", err)
+	}
+
+	now := time.Now().UTC()
+	v := Version{
+		ID:        now.Format(idLayout),
+		Interface: iface,
+		Time:      now,
+		User:      state.Invoker(),
+		Action:    action,
+		Size:      len(data),
+	}
+	meta, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return Version{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, v.ID+".conf"), data, 0600); err != nil {
+		return Version{}, fmt.Errorf("failed to write backup: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, v.ID+".json"), meta, 0600); err != nil {
+		return Version{}, fmt.Errorf("failed to write backup metadata: %v", err)
+	}
+	return v, nil
+}
+
+// List returns every snapshot of iface, newest first
+func (s *Store) List(iface string) ([]Version, error) {
+	if !wg.ValidInterfaceName(iface) {
+		return nil, fmt.Errorf("invalid interface name %q", iface)
+	}
+	entries, err := os.ReadDir(filepath.Join(s.Dir, iface))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, iface, e.Name()))
+		if err != nil {
+			continue
+		}
+		var v Version
+		if json.Unmarshal(data, &v) != nil || v.ID != strings.TrimSuffix(e.Name(), ".json") {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ID > versions[j].ID })
+	return versions, nil
+}
+
+// Load returns the config contents saved in a snapshot
+func (s *Store) Load(v Version) ([]byte, error) {
+	if !wg.ValidInterfaceName(v.Interface) || strings.ContainsAny(v.ID, `/\`) {
+		return nil, fmt.Errorf("invalid backup %q", v.ID)
+	}
+	return os.ReadFile(filepath.Join(s.Dir, v.Interface, v.ID+".conf"))
+}
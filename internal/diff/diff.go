@@ -0,0 +1,117 @@
+// Package diff computes line-based differences between two texts.
+package diff
+
+import "strings"
+
+// Op is the kind of change a line represents
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is one line of a diff
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the edit script that turns a into b, based on the
+// longest common subsequence. Config files are small, so the quadratic
+// table is fine.
+func Lines(a, b []string) []Line {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []Line
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Delete, a[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, Line{Delete, a[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, Line{Insert, b[j]})
+	}
+	return out
+}
+
+// Text diffs two strings line by line
+func Text(a, b string) []Line {
+	return Lines(splitLines(a), splitLines(b))
+}
+
+// Changed reports whether a diff contains any insertions or deletions
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Context trims runs of unchanged lines down to n lines around each
+// change. Dropped runs are replaced by a single Equal line holding "…".
+func Context(lines []Line, n int) []Line {
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+		for k := i - n; k <= i+n; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+
+	var out []Line
+	skipped := false
+	for i, l := range lines {
+		if keep[i] {
+			out = append(out, l)
+			skipped = false
+		} else if !skipped {
+			out = append(out, Line{Equal, "…"})
+			skipped = true
+		}
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
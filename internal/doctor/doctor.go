@@ -0,0 +1,432 @@
+// Package doctor checks that a machine is ready to run WireGuard and
+// says what to do about what is not
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"wireguard-tui/internal/wg"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	}
+	return "PASS"
+}
+
+// Check is the result of looking at one thing
+type Check struct {
+	Name   string
+	Status Status
+	// Detail says what was found
+	Detail string
+	// Hint says what to do about it, if anything
+	Hint string
+}
+
+// Env is the machine to check
+type Env struct {
+	// Runner probes the machine; nil means this one
+	Runner wg.Runner
+	// Client lists the interfaces and reads their configs, which may
+	// take privileges the probes do not have
+	Client wg.Client
+	// ConfigGlobs are where configs are looked for. Defaults to those of
+	// Client when it is a wg.LinuxClient, and to wg-quick's otherwise.
+	ConfigGlobs []string
+}
+
+// iface is an interface along with its parsed config, if it could be read
+type iface struct {
+	name string
+	cfg  *wg.Config
+}
+
+// Run runs every check, in the order they are best read in
+func (e Env) Run(ctx context.Context) []Check {
+	if e.Runner == nil {
+		e.Runner = wg.LocalRunner{}
+	}
+	list, listErr := e.Client.GetInterfaces(ctx)
+	var ifaces []iface
+	configs := make(map[string]*wg.Config)
+	for _, i := range list {
+		it := iface{name: i.Name}
+		if data, err := e.Client.ReadConfig(ctx, i.Name); err == nil {
+			it.cfg, _ = wg.ParseConfig(data)
+			configs[i.Name] = it.cfg
+		}
+		ifaces = append(ifaces, it)
+	}
+
+	servers := servers(ifaces)
+	return []Check{
+		e.checkWg(ctx),
+		e.checkWgQuick(ctx),
+		e.checkModule(ctx),
+		e.checkPrivileges(ctx, listErr),
+		e.checkResolvconf(ctx, ifaces),
+		e.checkForwarding(ctx, servers),
+		e.checkRPFilter(ctx, servers),
+		e.checkConfigDir(ctx, list),
+		e.checkPorts(ctx, list, configs),
+	}
+}
+
+// Failed counts the checks that failed
+func Failed(checks []Check) int {
+	n := 0
+	for _, c := range checks {
+		if c.Status == Fail {
+			n++
+		}
+	}
+	return n
+}
+
+// output runs a probe and returns its trimmed stdout
+func (e Env) output(ctx context.Context, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, wg.DefaultTimeout)
+	defer cancel()
+	var out bytes.Buffer
+	err := e.Runner.Run(ctx, nil, &out, nil, name, args...)
+	return strings.TrimSpace(out.String()), err
+}
+
+// which returns where a command is installed, or "" if it is not
+func (e Env) which(ctx context.Context, name string) string {
+	path, err := e.output(ctx, "sh", "-c", `command -v "$1"`, "sh", name)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func (e Env) checkWg(ctx context.Context) Check {
+	c := Check{Name: "wg"}
+	version, err := e.output(ctx, "wg", "--version")
+	if err != nil {
+		c.Status, c.Detail = Fail, "not installed"
+		c.Hint = (&wg.Error{Kind: wg.ErrMissingTool, Tool: "wg"}).Fix()
+		return c
+	}
+	// wireguard-tools v1.0.20210914 - https://git.zx2c4.com/wireguard-tools/
+	c.Detail, _, _ = strings.Cut(version, " - ")
+	return c
+}
+
+func (e Env) checkWgQuick(ctx context.Context) Check {
+	c := Check{Name: "wg-quick"}
+	path := e.which(ctx, "wg-quick")
+	if path == "" {
+		c.Status, c.Detail = Fail, "not installed"
+		c.Hint = (&wg.Error{Kind: wg.ErrMissingTool, Tool: "wg-quick"}).Fix()
+		return c
+	}
+	c.Detail = path
+	return c
+}
+
+func (e Env) checkModule(ctx context.Context) Check {
+	c := Check{Name: "kernel module"}
+	if version, err := e.output(ctx, "cat", "/sys/module/wireguard/version"); err == nil {
+		c.Detail = "loaded, version " + version
+		return c
+	}
+	if version, err := e.output(ctx, "modinfo", "-F", "version", "wireguard"); err == nil {
+		c.Status, c.Detail = Warn, "available but not loaded, version "+version
+		c.Hint = "it loads when an interface comes up; run modprobe wireguard to make sure it can"
+		return c
+	}
+	c.Status, c.Detail = Fail, "not loaded and not installed"
+	c.Hint = (&wg.Error{Kind: wg.ErrNoModule}).Fix()
+	return c
+}
+
+// checkPrivileges looks at who the probes run as. Not being root is fine
+// when WireGuard could be listed anyway, which means a helper does it.
+func (e Env) checkPrivileges(ctx context.Context, listErr error) Check {
+	c := Check{Name: "privileges"}
+	uid, err := e.output(ctx, "id", "-u")
+	switch {
+	case err != nil:
+		c.Status, c.Detail = Warn, fmt.Sprintf("cannot tell: %v", err)
+	case uid == "0":
+		c.Detail = "root"
+	case listErr == nil:
+		c.Detail = "uid " + uid + ", WireGuard is managed through the helper"
+	default:
+		c.Status, c.Detail = Fail, "uid "+uid+", cannot manage WireGuard"
+		c.Hint = (&wg.Error{Kind: wg.ErrPermission}).Fix()
+	}
+	return c
+}
+
+func (e Env) checkResolvconf(ctx context.Context, ifaces []iface) Check {
+	c := Check{Name: "resolvconf"}
+	var dns []string
+	for _, i := range ifaces {
+		if i.cfg != nil && i.cfg.Interface != nil && i.cfg.Interface.Get("DNS") != "" {
+			dns = append(dns, i.name)
+		}
+	}
+	path := e.which(ctx, "resolvconf")
+	switch {
+	case path != "":
+		c.Detail = path
+	case len(dns) > 0:
+		c.Status, c.Detail = Fail, "not installed, but DNS is set by "+strings.Join(dns, ", ")
+		c.Hint = "wg-quick needs it to apply DNS; install openresolv, or systemd-resolved's resolvconf"
+	default:
+		c.Detail = "not installed, no config sets DNS"
+	}
+	return c
+}
+
+// servers returns the interfaces that look like they route for others:
+// they listen on a fixed port and none of their peers is a default route
+func servers(ifaces []iface) []iface {
+	var out []iface
+	for _, i := range ifaces {
+		if i.cfg == nil || i.cfg.Interface == nil || i.cfg.Interface.Get("ListenPort") == "" || len(i.cfg.Peers) == 0 {
+			continue
+		}
+		gateway := slices.ContainsFunc(i.cfg.Peers, func(p *wg.Section) bool {
+			return slices.ContainsFunc(p.List("AllowedIPs"), func(s string) bool {
+				prefix, err := wg.ParsePrefix(s)
+				return err == nil && prefix.Bits() == 0
+			})
+		})
+		if !gateway {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func names(ifaces []iface) string {
+	var out []string
+	for _, i := range ifaces {
+		out = append(out, i.name)
+	}
+	return strings.Join(out, ", ")
+}
+
+// hasIPv6 reports whether any of the interfaces has an IPv6 address
+func hasIPv6(ifaces []iface) bool {
+	for _, i := range ifaces {
+		for _, a := range i.cfg.Interface.List("Address") {
+			if prefix, err := netip.ParsePrefix(a); err == nil && prefix.Addr().Is6() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e Env) checkForwarding(ctx context.Context, servers []iface) Check {
+	c := Check{Name: "ip forwarding"}
+	if len(servers) == 0 {
+		c.Detail = "no server-style interfaces"
+		return c
+	}
+	sysctls := []string{"net.ipv4.ip_forward"}
+	if hasIPv6(servers) {
+		sysctls = append(sysctls, "net.ipv6.conf.all.forwarding")
+	}
+	var off []string
+	for _, key := range sysctls {
+		if v, err := e.sysctl(ctx, key); err == nil && v == "0" {
+			off = append(off, key)
+		}
+	}
+	if len(off) == 0 {
+		c.Detail = "enabled for " + names(servers)
+		return c
+	}
+	c.Status = Warn
+	c.Detail = fmt.Sprintf("%s off, peers of %s cannot reach beyond this machine", strings.Join(off, ", "), names(servers))
+	c.Hint = fmt.Sprintf("sysctl -w %s=1, and add it to /etc/sysctl.d to keep it", off[0])
+	return c
+}
+
+// checkRPFilter warns about strict reverse path filtering, which drops
+// forwarded packets whose source the kernel would not route back the
+// same way. The effective mode of an interface is the higher of its own
+// and "all".
+func (e Env) checkRPFilter(ctx context.Context, servers []iface) Check {
+	c := Check{Name: "rp_filter"}
+	if len(servers) == 0 {
+		c.Detail = "no server-style interfaces"
+		return c
+	}
+	all, _ := e.sysctl(ctx, "net.ipv4.conf.all.rp_filter")
+	var strict []string
+	for _, i := range servers {
+		mode := all
+		if own, err := e.sysctl(ctx, "net.ipv4.conf."+i.name+".rp_filter"); err == nil && own > mode {
+			mode = own
+		}
+		if mode == "1" {
+			strict = append(strict, i.name)
+		}
+	}
+	if len(strict) == 0 {
+		c.Detail = "loose or off for " + names(servers)
+		return c
+	}
+	c.Status = Warn
+	c.Detail = "strict on " + strings.Join(strict, ", ")
+	c.Hint = "if forwarded traffic is dropped, sysctl -w net.ipv4.conf.all.rp_filter=2 for loose filtering"
+	return c
+}
+
+// sysctl reads a kernel setting from /proc/sys
+func (e Env) sysctl(ctx context.Context, key string) (string, error) {
+	return e.output(ctx, "cat", "/proc/sys/"+strings.ReplaceAll(key, ".", "/"))
+}
+
+// checkConfigDir makes sure private keys are not readable by others, in
+// every directory configs are looked for and in every config found.
+// wg-quick only warns about that.
+func (e Env) checkConfigDir(ctx context.Context, list []wg.Interface) Check {
+	globs := e.ConfigGlobs
+	if lc, ok := wg.As[*wg.LinuxClient](e.Client); ok && len(globs) == 0 {
+		globs = lc.ConfigGlobs
+	}
+	if len(globs) == 0 {
+		globs = []string{wg.ConfigPath("*")}
+	}
+	var dirs, files []string
+	for _, g := range globs {
+		// A directory that is a pattern itself is covered by the
+		// configs found in it
+		if dir := filepath.Dir(g); !slices.Contains(dirs, dir) && !strings.ContainsAny(dir, "*?[") {
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, i := range list {
+		if i.ConfigFile == "" {
+			continue
+		}
+		files = append(files, i.ConfigFile)
+		if dir := filepath.Dir(i.ConfigFile); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	c := Check{Name: strings.Join(dirs, ", ")}
+	args := append([]string{"-c", `stat -c '%a %U %n' -- "$@" 2>/dev/null`, "sh"}, dirs...)
+	out, _ := e.output(ctx, "sh", append(args, files...)...)
+	modes := make(map[string]string)
+	var open, openPaths, foreign, foreignPaths []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		mode, err := strconv.ParseUint(fields[0], 8, 32)
+		if err != nil {
+			continue
+		}
+		if slices.Contains(dirs, fields[2]) {
+			modes[fields[2]] = fields[0]
+			if mode&0o007 != 0 {
+				open = append(open, fmt.Sprintf("%s (%s)", fields[2], fields[0]))
+				openPaths = append(openPaths, "chmod 700 "+fields[2])
+			}
+			continue
+		}
+		name := fields[2]
+		if len(dirs) == 1 {
+			name = filepath.Base(name)
+		}
+		if mode&0o077 != 0 {
+			open = append(open, fmt.Sprintf("%s (%s)", name, fields[0]))
+			openPaths = append(openPaths, "chmod 600 "+fields[2])
+		}
+		if fields[1] != "root" {
+			foreign = append(foreign, name+" owned by "+fields[1])
+			foreignPaths = append(foreignPaths, fields[2])
+		}
+	}
+	var missing, dirModes []string
+	for _, dir := range dirs {
+		if modes[dir] == "" {
+			missing = append(missing, dir)
+		} else {
+			dirModes = append(dirModes, modes[dir])
+		}
+	}
+	switch {
+	case len(missing) == len(dirs):
+		c.Status, c.Detail = Warn, "does not exist"
+		c.Hint = "mkdir -m 700 " + strings.Join(missing, " ")
+	case len(open) > 0:
+		c.Status, c.Detail = Fail, "readable by others: "+strings.Join(open, ", ")
+		c.Hint = strings.Join(openPaths, "; ")
+	case len(foreign) > 0:
+		c.Status, c.Detail = Warn, strings.Join(foreign, ", ")
+		c.Hint = "chown root: " + strings.Join(foreignPaths, " ")
+	case len(missing) > 0:
+		c.Status, c.Detail = Warn, strings.Join(missing, ", ")+" does not exist"
+		c.Hint = "mkdir -m 700 " + strings.Join(missing, " ")
+	default:
+		c.Detail = "mode " + strings.Join(dirModes, ", ") + ", configs private"
+	}
+	return c
+}
+
+// checkPorts finds ListenPorts that cannot be bound: shared by two
+// configs, or taken by another socket while the interface is down
+func (e Env) checkPorts(ctx context.Context, list []wg.Interface, configs map[string]*wg.Config) Check {
+	c := Check{Name: "udp ports"}
+	var ports []int
+	for _, i := range list {
+		if port := wg.ListenPort(i, configs[i.Name]); port > 0 {
+			ports = append(ports, port)
+		}
+	}
+	// The client can name the processes holding the ports, as root
+	var sockets []wg.UDPSocket
+	if pi, ok := wg.As[wg.PortInspector](e.Client); ok {
+		sockets, _ = pi.UDPSockets(ctx, ports)
+	} else {
+		table, _ := e.output(ctx, "cat", "/proc/net/udp", "/proc/net/udp6")
+		sockets = wg.ParseProcNetUDP([]byte(table))
+	}
+
+	conflicts := wg.PortConflicts(list, configs, sockets)
+	if len(conflicts) > 0 {
+		var lines []string
+		for _, i := range list {
+			if conflict := conflicts[i.Name]; conflict != "" {
+				lines = append(lines, i.Name+": "+conflict)
+			}
+		}
+		c.Status, c.Detail = Fail, strings.Join(lines, "; ")
+		c.Hint = (&wg.Error{Kind: wg.ErrAddressInUse}).Fix()
+		return c
+	}
+	c.Detail = fmt.Sprintf("no conflicts among %d ports", len(ports))
+	return c
+}
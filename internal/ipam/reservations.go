@@ -0,0 +1,103 @@
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"wireguard-tui/internal/state"
+)
+
+// Reservation holds an address back from allocation, e.g. for a peer
+// that has been promised an IP but not configured yet
+type Reservation struct {
+	Addr netip.Addr `json:"addr"`
+	Note string     `json:"note,omitempty"`
+	User string     `json:"user"`
+	Time time.Time  `json:"time"`
+}
+
+// ReservationStore persists reservations for all interfaces in one JSON file
+type ReservationStore struct {
+	Path string
+}
+
+func NewReservationStore(path string) *ReservationStore {
+	return &ReservationStore{Path: path}
+}
+
+// Load returns the reservations of every interface, keyed by interface name
+func (s *ReservationStore) Load() (map[string][]Reservation, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return map[string][]Reservation{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	all := map[string][]Reservation{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return all, nil
+}
+
+// Reserve adds a reservation for addr on iface
+func (s *ReservationStore) Reserve(iface string, addr netip.Addr, note string) error {
+	all, err := s.Load()
+	if err != nil {
+		return err
+	}
+	for _, r := range all[iface] {
+		if r.Addr == addr {
+			return fmt.Errorf("%s is already reserved", addr)
+		}
+	}
+	all[iface] = append(all[iface], Reservation{
+		Addr: addr,
+		Note: note,
+		User: state.Invoker(),
+		Time: time.Now(),
+	})
+	sort.Slice(all[iface], func(i, j int) bool { return all[iface][i].Addr.Less(all[iface][j].Addr) })
+	return s.save(all)
+}
+
+// Release removes the reservation for addr on iface
+func (s *ReservationStore) Release(iface string, addr netip.Addr) error {
+	all, err := s.Load()
+	if err != nil {
+		return err
+	}
+	kept := all[iface][:0]
+	for _, r := range all[iface] {
+		if r.Addr != addr {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == 0 {
+		delete(all, iface)
+	} else {
+		all[iface] = kept
+	}
+	return s.save(all)
+}
+
+func (s *ReservationStore) save(all map[string][]Reservation) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
@@ -0,0 +1,329 @@
+// Package monitor polls WireGuard, keeps a traffic history and raises
+// events when interfaces or peers change state. The TUI runs a collector of
+// its own, or attaches to `wireguard-tui daemon`, which keeps collecting
+// while nobody is watching.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"wireguard-tui/internal/wg"
+)
+
+// StaleAfter is how old a handshake may get before the session is
+// considered gone, by default. WireGuard rejects sessions older than
+// three minutes.
+const StaleAfter = 3 * time.Minute
+
+// Point is the traffic of an interface, summed over its peers
+type Point struct {
+	Time time.Time `json:"time"`
+	Rx   int64     `json:"rx"`
+	Tx   int64     `json:"tx"`
+}
+
+// Event kinds
+const (
+	EventUp        = "up"
+	EventDown      = "down"
+	EventStale     = "stale"
+	EventRecovered = "recovered"
+)
+
+// Event is something that happened between two polls
+type Event struct {
+	Time      time.Time `json:"time"`
+	Interface string    `json:"interface"`
+	Peer      string    `json:"peer,omitempty"`
+	Kind      string    `json:"kind"`
+	// After is the handshake age a stale event was raised at
+	After time.Duration `json:"after,omitempty"`
+}
+
+// Alert tells whether the event needs someone's attention
+func (e Event) Alert() bool {
+	return e.Kind == EventDown || e.Kind == EventStale
+}
+
+// Alerts lists the kinds of events that need someone's attention; nil
+// means the default of Event.Alert
+type Alerts []string
+
+// Match tells whether the event is an alert
+func (a Alerts) Match(e Event) bool {
+	if a == nil {
+		return e.Alert()
+	}
+	return slices.Contains(a, e.Kind)
+}
+
+func (e Event) String() string {
+	switch e.Kind {
+	case EventUp:
+		return e.Interface + " came up"
+	case EventDown:
+		return e.Interface + " went down"
+	case EventStale:
+		after := e.After
+		if after == 0 {
+			after = StaleAfter
+		}
+		return fmt.Sprintf("%s: no handshake from %s for %s", e.Interface, e.Peer, after)
+	case EventRecovered:
+		return fmt.Sprintf("%s: %s is back", e.Interface, e.Peer)
+	}
+	return e.Interface + ": " + e.Kind
+}
+
+// Snapshot is the state as of the last poll
+type Snapshot struct {
+	Time       time.Time            `json:"time"`
+	Interfaces []wg.Interface       `json:"interfaces"`
+	Peers      map[string][]wg.Peer `json:"peers"`
+	// History holds the most recent points per interface, oldest first
+	History map[string][]Point `json:"history"`
+	// Events holds the most recent events, oldest first
+	Events []Event `json:"events"`
+}
+
+// Source hands out snapshots. fresh asks for a poll first instead of
+// whatever the last scheduled poll found; points and events limit how
+// much history comes along. A snapshot may come with an error, holding
+// only the interfaces that could still be listed.
+type Source interface {
+	Snapshot(ctx context.Context, fresh bool, points, events int) (*Snapshot, error)
+}
+
+// Collector polls a wg.Client and remembers what it saw
+type Collector struct {
+	Client wg.Client
+	// Resolution is the least time between two history points, so
+	// extra polls do not crowd the history
+	Resolution time.Duration
+	// Retention is how far back history and events go
+	Retention time.Duration
+	// StaleAfter is how old a handshake may get before a stale event
+	StaleAfter time.Duration
+
+	// polling is held for a whole poll. The UI, the fleet view and Run
+	// may all poll at once, and two polls overlapping would compare
+	// against each other's half-applied state and duplicate events.
+	polling sync.Mutex
+
+	mu   sync.Mutex
+	last *Snapshot
+	// partial holds what the last poll could list when it failed
+	partial *Snapshot
+	history map[string][]Point
+	events  []Event
+	stale   map[string]bool
+	subs    map[chan struct{}]bool
+}
+
+func NewCollector(client wg.Client) *Collector {
+	return &Collector{
+		Client:     client,
+		Resolution: 5 * time.Second,
+		Retention:  24 * time.Hour,
+		StaleAfter: StaleAfter,
+		history:    make(map[string][]Point),
+		stale:      make(map[string]bool),
+		subs:       make(map[chan struct{}]bool),
+	}
+}
+
+// Subscribe returns a channel that is signalled after every poll, and a
+// function to stop the signals. Signals are dropped for a reader that
+// falls behind; it finds the latest state with Latest anyway.
+func (c *Collector) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	c.mu.Lock()
+	c.subs[ch] = true
+	c.mu.Unlock()
+	return ch, func() {
+		c.mu.Lock()
+		delete(c.subs, ch)
+		c.mu.Unlock()
+	}
+}
+
+// Poll reads the current state and returns the events it caused
+func (c *Collector) Poll(ctx context.Context) ([]Event, error) {
+	c.polling.Lock()
+	defer c.polling.Unlock()
+	ifaces, err := c.Client.GetInterfaces(ctx)
+	if err != nil {
+		// Interfaces whose state is unknown would all look down, so
+		// they stay out of the history and events
+		c.mu.Lock()
+		c.partial = nil
+		if len(ifaces) > 0 {
+			c.partial = &Snapshot{Time: time.Now(), Interfaces: ifaces, Peers: map[string][]wg.Peer{}}
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+	peers := make(map[string][]wg.Peer)
+	for _, iface := range ifaces {
+		if iface.Status == wg.InterfaceUp {
+			p, _ := c.Client.GetPeers(ctx, iface.Name)
+			peers[iface.Name] = p
+		}
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	events := c.compare(now, ifaces, peers)
+	c.events = append(c.events, events...)
+	c.last = &Snapshot{Time: now, Interfaces: ifaces, Peers: peers}
+
+	for _, iface := range ifaces {
+		var p Point
+		p.Time = now
+		for _, peer := range peers[iface.Name] {
+			p.Rx += peer.TransferRx
+			p.Tx += peer.TransferTx
+		}
+		h := c.history[iface.Name]
+		if n := len(h); n > 0 && now.Sub(h[n-1].Time) < c.Resolution {
+			continue
+		}
+		c.history[iface.Name] = append(h, p)
+	}
+	c.expire(now)
+	for ch := range c.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return events, nil
+}
+
+// compare turns the difference between the last poll and this one into
+// events. The first poll only sets the baseline.
+func (c *Collector) compare(now time.Time, ifaces []wg.Interface, peers map[string][]wg.Peer) []Event {
+	var events []Event
+	if c.last != nil {
+		was := make(map[string]wg.InterfaceStatus)
+		for _, iface := range c.last.Interfaces {
+			was[iface.Name] = iface.Status
+		}
+		for _, iface := range ifaces {
+			before, ok := was[iface.Name]
+			if !ok || before == iface.Status {
+				continue
+			}
+			kind := EventDown
+			if iface.Status == wg.InterfaceUp {
+				kind = EventUp
+			}
+			events = append(events, Event{Time: now, Interface: iface.Name, Kind: kind})
+		}
+	}
+
+	// Only a peer that had a session can lose it, and peers of an
+	// interface that went down are covered by its own event
+	stale := make(map[string]bool)
+	for name, list := range peers {
+		for _, p := range list {
+			key := name + " " + p.PublicKey
+			gone := p.LatestHandshake.IsZero() || now.Sub(p.LatestHandshake) > c.StaleAfter
+			known, seen := c.stale[key]
+			stale[key] = gone
+			if !seen || c.last == nil || known == gone {
+				continue
+			}
+			e := Event{Time: now, Interface: name, Peer: p.PublicKey, Kind: EventStale, After: c.StaleAfter}
+			if !gone {
+				e.Kind, e.After = EventRecovered, 0
+			}
+			events = append(events, e)
+		}
+	}
+	c.stale = stale
+	return events
+}
+
+func (c *Collector) expire(now time.Time) {
+	cutoff := now.Add(-c.Retention)
+	for name, h := range c.history {
+		i := 0
+		for i < len(h) && h[i].Time.Before(cutoff) {
+			i++
+		}
+		if i == len(h) {
+			delete(c.history, name)
+			continue
+		}
+		c.history[name] = h[i:]
+	}
+	i := 0
+	for i < len(c.events) && c.events[i].Time.Before(cutoff) {
+		i++
+	}
+	c.events = c.events[i:]
+}
+
+// Snapshot implements Source for a collector in this process. When the
+// poll fails, what it could still list comes along with the error.
+func (c *Collector) Snapshot(ctx context.Context, fresh bool, points, events int) (*Snapshot, error) {
+	c.mu.Lock()
+	polled := c.last != nil
+	c.mu.Unlock()
+	if fresh || !polled {
+		if _, err := c.Poll(ctx); err != nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.partial, err
+		}
+	}
+	return c.Latest(points, events), nil
+}
+
+// Latest returns the last poll with up to points history points per
+// interface and the last events events, without polling
+func (c *Collector) Latest(points, events int) *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		return &Snapshot{Peers: map[string][]wg.Peer{}, History: map[string][]Point{}}
+	}
+	s := *c.last
+	s.History = make(map[string][]Point)
+	for name, h := range c.history {
+		if points < len(h) {
+			h = h[len(h)-points:]
+		}
+		s.History[name] = append([]Point(nil), h...)
+	}
+	ev := c.events
+	if events < len(ev) {
+		ev = ev[len(ev)-events:]
+	}
+	s.Events = append([]Event(nil), ev...)
+	return &s
+}
+
+// Run polls every interval until ctx is done, handing each batch of
+// events to notify
+func (c *Collector) Run(ctx context.Context, interval time.Duration, notify func([]Event, error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		events, err := c.Poll(ctx)
+		if notify != nil && ctx.Err() == nil && (err != nil || len(events) > 0) {
+			notify(events, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
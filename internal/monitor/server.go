@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"wireguard-tui/internal/helper"
+	"wireguard-tui/internal/wg"
+)
+
+// DefaultSocket is where `wireguard-tui daemon` listens
+const DefaultSocket = "/run/wireguard-tui/daemon.sock"
+
+// Limits on what one request may ask for
+const (
+	maxPoints = 24 * 60 * 60
+	maxEvents = 10000
+)
+
+// Request asks the daemon for a snapshot
+type Request struct {
+	Fresh  bool `json:"fresh,omitempty"`
+	Points int  `json:"points,omitempty"`
+	Events int  `json:"events,omitempty"`
+}
+
+// Response carries a snapshot or an error
+type Response struct {
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Failure is the known cause of Error, if any
+	Failure *wg.Error `json:"failure,omitempty"`
+}
+
+// Server hands out snapshots of a collector to root and one other user
+type Server struct {
+	Collector *Collector
+	UID       int
+}
+
+func NewServer(c *Collector, uid int) *Server {
+	return &Server{Collector: c, UID: uid}
+}
+
+// Serve handles connections until l is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn.(*net.UnixConn))
+	}
+}
+
+func (s *Server) handle(conn *net.UnixConn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	enc := json.NewEncoder(conn)
+
+	// Snapshots show peers and endpoints, which wg only shows to root
+	uid, err := helper.PeerUID(conn)
+	if err != nil || (uid != 0 && uid != s.UID) {
+		log.Printf("refused connection from uid %d", uid)
+		enc.Encode(Response{Error: "permission denied"})
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(conn, 4096)).Decode(&req); err != nil {
+		enc.Encode(Response{Error: "malformed request"})
+		return
+	}
+	req.Points = min(max(req.Points, 0), maxPoints)
+	req.Events = min(max(req.Events, 0), maxEvents)
+
+	snap, err := s.Collector.Snapshot(context.Background(), req.Fresh, req.Points, req.Events)
+	resp := Response{Snapshot: snap}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Failure = wg.Cause(err)
+	}
+	enc.Encode(resp)
+}
+
+// Client reads snapshots from a daemon
+type Client struct {
+	Socket string
+}
+
+func NewClient(socket string) *Client {
+	return &Client{Socket: socket}
+}
+
+// Snapshot implements Source
+func (c *Client) Snapshot(ctx context.Context, fresh bool, points, events int) (*Snapshot, error) {
+	dialer := net.Dialer{Timeout: 2 * time.Second}
+	conn, err := dialer.DialContext(ctx, "unix", c.Socket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach daemon: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := json.NewEncoder(conn).Encode(Request{Fresh: fresh, Points: points, Events: events}); err != nil {
+		return nil, fmt.Errorf("cannot reach daemon: %v", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("daemon hung up: %v", err)
+	}
+	if resp.Failure != nil {
+		resp.Failure.Err = errors.New(resp.Error)
+		return resp.Snapshot, resp.Failure
+	}
+	if resp.Error != "" {
+		return resp.Snapshot, errors.New(resp.Error)
+	}
+	return resp.Snapshot, nil
+}
+
+// Ping checks that a daemon is running and lets us in
+func (c *Client) Ping() error {
+	_, err := c.Snapshot(context.Background(), false, 0, 0)
+	return err
+}
@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"time"
+
+	"wireguard-tui/internal/audit"
+	"wireguard-tui/internal/wg"
+)
+
+// Client wraps a wg.Client and refuses the calls the policy does not allow.
+// The UI checks the policy too, but this is what actually enforces it.
+type Client struct {
+	wg.Client
+	policy *Policy
+}
+
+// Wrap returns a client that enforces p on c
+func Wrap(c wg.Client, p *Policy) *Client {
+	return &Client{Client: c, policy: p}
+}
+
+// Unwrap returns the client the policy is enforced on
+func (c *Client) Unwrap() wg.Client {
+	return c.Client
+}
+
+func (c *Client) ToggleInterface(ctx context.Context, name string, up bool, out io.Writer) error {
+	action := Down
+	if up {
+		action = Up
+	}
+	if err := c.policy.Check(action, name); err != nil {
+		return err
+	}
+	return c.Client.ToggleInterface(ctx, name, up, out)
+}
+
+// ReadConfig masks the private and preshared keys unless the policy
+// allows reading them
+func (c *Client) ReadConfig(ctx context.Context, name string) ([]byte, error) {
+	data, err := c.Client.ReadConfig(ctx, name)
+	if err != nil || c.policy.Check(Secrets, name) == nil {
+		return data, err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		lines[i] = audit.Redact(line)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func (c *Client) WriteConfig(ctx context.Context, name string, data []byte) error {
+	if err := c.policy.Check(Edit, name); err != nil {
+		return err
+	}
+	return c.Client.WriteConfig(ctx, name, data)
+}
+
+func (c *Client) SyncConfig(ctx context.Context, name string) error {
+	if err := c.policy.Check(Reload, name); err != nil {
+		return err
+	}
+	return c.Client.SyncConfig(ctx, name)
+}
+
+func (c *Client) SetPrivateKey(ctx context.Context, name string, privateKey string) error {
+	if err := c.policy.Check(Rotate, name); err != nil {
+		return err
+	}
+	return c.Client.SetPrivateKey(ctx, name, privateKey)
+}
+
+func (c *Client) RouteDevice(ctx context.Context, dst netip.Addr) (string, error) {
+	ri, ok := wg.As[wg.RouteInspector](c.Client)
+	if !ok {
+		return "", fmt.Errorf("route lookup not supported")
+	}
+	return ri.RouteDevice(ctx, dst)
+}
+
+func (c *Client) UDPSockets(ctx context.Context, ports []int) ([]wg.UDPSocket, error) {
+	pi, ok := wg.As[wg.PortInspector](c.Client)
+	if !ok {
+		return nil, fmt.Errorf("socket listing not supported")
+	}
+	return pi.UDPSockets(ctx, ports)
+}
+
+// ArmSafetyTimer brings the interface up later, so it needs permission
+// to do that, besides the one to take it down
+func (c *Client) ArmSafetyTimer(ctx context.Context, name string, after time.Duration) (func() error, error) {
+	st, ok := wg.As[wg.SafetyTimer](c.Client)
+	if !ok {
+		return nil, fmt.Errorf("safety timer not supported")
+	}
+	if err := c.policy.Check(Up, name); err != nil {
+		return nil, err
+	}
+	return st.ArmSafetyTimer(ctx, name, after)
+}
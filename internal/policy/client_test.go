@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"wireguard-tui/internal/wg"
+)
+
+func TestClientReadConfigRedactsWithoutSecrets(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy *Policy
+		hidden bool
+	}{
+		{"no policy", nil, false},
+		{"read-only", ReadOnlyPolicy(), true},
+		{"toggle only", &Policy{Default: &Rule{Actions: []string{"up", "down"}, Interfaces: []string{"*"}}}, true},
+		{"secrets", &Policy{Default: &Rule{Actions: []string{"secrets"}, Interfaces: []string{"wg0"}}}, false},
+		{"secrets elsewhere", &Policy{Default: &Rule{Actions: []string{"secrets"}, Interfaces: []string{"wg1"}}}, true},
+		{"edit", &Policy{Default: &Rule{Actions: []string{"edit"}, Interfaces: []string{"*"}}}, false},
+		{"everything", &Policy{Default: &Rule{Actions: []string{"*"}, Interfaces: []string{"*"}}}, false},
+	} {
+		c := Wrap(wg.NewMockClient(), tt.policy)
+		data, err := c.ReadConfig(context.Background(), "wg0")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		cfg := string(data)
+		leaked := strings.Contains(cfg, "zMCR7rbJhpQglJZOX") || strings.Contains(cfg, "MYnl1yEieHncUS5t8O9")
+		switch {
+		case tt.hidden && leaked:
+			t.Errorf("%s: got the keys:\n%s", tt.name, cfg)
+		case tt.hidden && !strings.Contains(cfg, "PrivateKey = [redacted]"):
+			t.Errorf("%s: PrivateKey not redacted:\n%s", tt.name, cfg)
+		case !tt.hidden && !leaked:
+			t.Errorf("%s: keys withheld:\n%s", tt.name, cfg)
+		}
+		if !strings.Contains(cfg, "ListenPort = 51820") {
+			t.Errorf("%s: lost the rest of the config:\n%s", tt.name, cfg)
+		}
+	}
+}
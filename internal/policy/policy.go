@@ -0,0 +1,254 @@
+// Package policy decides which changes the person running the app may
+// make, so the dashboard can be handed to people who should only watch.
+package policy
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultPath is read when no policy file is given; it is fine for it not
+// to exist
+const DefaultPath = "/etc/wireguard-tui/policy.toml"
+
+// Action is a kind of change to an interface, or reading its keys
+type Action string
+
+const (
+	Up     Action = "up"
+	Down   Action = "down"
+	Edit   Action = "edit"   // write the config file
+	Reload Action = "reload" // wg syncconf
+	Rotate Action = "rotate" // replace the private key
+	// Secrets is reading the private and preshared keys in a config.
+	// Edit and Rotate grant it too, as both need the keys.
+	Secrets Action = "secrets"
+)
+
+// Actions lists every action a policy can grant
+var Actions = []Action{Up, Down, Edit, Reload, Rotate, Secrets}
+
+var verbs = map[Action]string{
+	Up:      "bring up",
+	Down:    "take down",
+	Edit:    "edit the config of",
+	Reload:  "reload",
+	Rotate:  "rotate the key of",
+	Secrets: "read the keys of",
+}
+
+// Rule grants actions on the interfaces matching any of the glob patterns
+type Rule struct {
+	Actions    []string `toml:"actions"`
+	Interfaces []string `toml:"interfaces"`
+}
+
+func (r *Rule) allows(a Action, iface string) bool {
+	matched := false
+	for _, pattern := range r.Interfaces {
+		if ok, _ := path.Match(pattern, iface); ok {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	return r.grants(a)
+}
+
+// grants reports whether the rule lists a, wherever it applies
+func (r *Rule) grants(a Action) bool {
+	for _, s := range r.Actions {
+		if s == "*" || Action(s) == a || a == Secrets && (Action(s) == Edit || Action(s) == Rotate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy is what the current user may do. A nil Policy allows everything.
+type Policy struct {
+	ReadOnly bool            `toml:"read_only"`
+	Default  *Rule           `toml:"default"`
+	Groups   map[string]Rule `toml:"groups"`
+
+	// user and the groups they are in that the policy mentions
+	user   string
+	groups []string
+	// within is the policy this one narrows, which has to allow an
+	// action as well
+	within *Policy
+}
+
+// ReadOnlyPolicy allows nothing
+func ReadOnlyPolicy() *Policy {
+	return &Policy{ReadOnly: true}
+}
+
+// Load reads the policy file and applies it to the given user. Everyone
+// gets the [default] rule, plus the rules of each listed group they are in.
+func Load(filename, username string) (*Policy, error) {
+	p := &Policy{}
+	md, err := toml.DecodeFile(filename, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy %s: %v", filename, err)
+	}
+	if undec := md.Undecoded(); len(undec) > 0 {
+		return nil, fmt.Errorf("policy %s: unknown key %s", filename, undec[0])
+	}
+	rules := make([]*Rule, 0, len(p.Groups)+1)
+	rules = append(rules, p.Default)
+	for _, r := range p.Groups {
+		rules = append(rules, &r)
+	}
+	for _, r := range rules {
+		if r == nil {
+			continue
+		}
+		for _, a := range r.Actions {
+			if a != "*" && verbs[Action(a)] == "" {
+				return nil, fmt.Errorf("policy %s: unknown action %q", filename, a)
+			}
+		}
+		for _, pattern := range r.Interfaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("policy %s: bad interface pattern %q", filename, pattern)
+			}
+		}
+	}
+
+	p.user = username
+	for _, g := range userGroups(username) {
+		if _, ok := p.Groups[g]; ok {
+			p.groups = append(p.groups, g)
+		}
+	}
+	sort.Strings(p.groups)
+	return p, nil
+}
+
+// Narrow returns a policy allowing only what both p and q allow, so a
+// policy given on the command line cannot grant more than the system one
+func (p *Policy) Narrow(q *Policy) *Policy {
+	switch {
+	case p == nil || q != nil && q.ReadOnly:
+		return q
+	case q == nil || p.ReadOnly:
+		return p
+	}
+	n := *q
+	n.within = p.Narrow(q.within)
+	return &n
+}
+
+// LoadDefault loads DefaultPath if it exists, and returns nil otherwise
+func LoadDefault(username string) (*Policy, error) {
+	if _, err := os.Stat(DefaultPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return Load(DefaultPath, username)
+}
+
+func userGroups(username string) []string {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, id := range ids {
+		if g, err := user.LookupGroupId(id); err == nil {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
+
+// DeniedError is returned for an action the policy does not allow
+type DeniedError struct {
+	Action    Action
+	Interface string
+	p         *Policy
+}
+
+func (e *DeniedError) Error() string {
+	if e.p.ReadOnly {
+		return fmt.Sprintf("read-only mode: cannot %s %s", verbs[e.Action], e.Interface)
+	}
+	who := e.p.user
+	if len(e.p.groups) > 0 {
+		who += " (" + strings.Join(e.p.groups, ", ") + ")"
+	}
+	return fmt.Sprintf("policy: %s may not %s %s", who, verbs[e.Action], e.Interface)
+}
+
+// Check returns a *DeniedError unless a may be done on iface
+func (p *Policy) Check(a Action, iface string) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.within.Check(a, iface); err != nil {
+		return err
+	}
+	if !p.ReadOnly {
+		for _, r := range p.rules() {
+			if r.allows(a, iface) {
+				return nil
+			}
+		}
+	}
+	return &DeniedError{Action: a, Interface: iface, p: p}
+}
+
+func (p *Policy) rules() []*Rule {
+	var rules []*Rule
+	if p.Default != nil {
+		rules = append(rules, p.Default)
+	}
+	for _, g := range p.groups {
+		r := p.Groups[g]
+		rules = append(rules, &r)
+	}
+	return rules
+}
+
+// Permits reports whether a is allowed on at least one interface, which
+// decides whether the key for it is offered at all
+func (p *Policy) Permits(a Action) bool {
+	if p == nil {
+		return true
+	}
+	if p.ReadOnly || !p.within.Permits(a) {
+		return false
+	}
+	for _, r := range p.rules() {
+		if r.grants(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Label is a short description for the header, or "" when unrestricted
+func (p *Policy) Label() string {
+	switch {
+	case p == nil:
+		return ""
+	case p.ReadOnly:
+		return "READ-ONLY"
+	case len(p.groups) > 0:
+		return "policy: " + strings.Join(p.groups, ",")
+	default:
+		return "policy: default"
+	}
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// load writes a policy file and loads it for the current user
+func load(t *testing.T, text string) (*Policy, error) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	filename := filepath.Join(t.TempDir(), "policy.toml")
+	if err := os.WriteFile(filename, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(filename, u.Username)
+}
+
+func TestLoadMatchesGroups(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	g, err := user.LookupGroupId(u.Gid)
+	if err != nil {
+		t.Skip(err)
+	}
+	p, err := load(t, `
+[default]
+actions = ["up"]
+interfaces = ["*"]
+
+[groups.`+g.Name+`]
+actions = ["down", "edit"]
+interfaces = ["wg-office*"]
+
+[groups.no-such-group-here]
+actions = ["*"]
+interfaces = ["*"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		action Action
+		iface  string
+		want   bool
+	}{
+		{Up, "wg0", true},
+		{Down, "wg0", false},
+		{Down, "wg-office1", true},
+		{Edit, "wg-office1", true},
+		{Secrets, "wg-office1", true},
+		{Secrets, "wg0", false},
+		{Rotate, "wg-office1", false},
+		{Reload, "wg0", false},
+	} {
+		if got := p.Check(tt.action, tt.iface) == nil; got != tt.want {
+			t.Errorf("%s %s: allowed %v, want %v", tt.action, tt.iface, got, tt.want)
+		}
+	}
+	if want := "policy: " + g.Name; p.Label() != want {
+		t.Errorf("label is %q, want %q", p.Label(), want)
+	}
+	var denied *DeniedError
+	if err := p.Check(Rotate, "wg0"); !errors.As(err, &denied) || !strings.Contains(err.Error(), u.Username+" ("+g.Name+") may not rotate the key of wg0") {
+		t.Errorf("got %v, want a denial naming the user and group", err)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	for _, tt := range []struct {
+		name, text, want string
+	}{
+		{"unknown action", "[default]\nactions = [\"reboot\"]\ninterfaces = [\"*\"]\n", `unknown action "reboot"`},
+		{"unknown action in a group", "[groups.ops]\nactions = [\"up\", \"shell\"]\ninterfaces = [\"*\"]\n", `unknown action "shell"`},
+		{"bad pattern", "[default]\nactions = [\"up\"]\ninterfaces = [\"wg[\"]\n", `bad interface pattern "wg["`},
+		{"unknown key", "[default]\nactions = [\"up\"]\ninterface = [\"*\"]\n", "unknown key default.interface"},
+		{"not toml", "[default\n", "failed to load policy"},
+	} {
+		_, err := load(t, tt.text)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestReadOnlyPolicy(t *testing.T) {
+	p := ReadOnlyPolicy()
+	for _, a := range Actions {
+		if p.Permits(a) {
+			t.Errorf("%s is permitted", a)
+		}
+		err := p.Check(a, "wg0")
+		if err == nil || !strings.HasPrefix(err.Error(), "read-only mode: cannot ") {
+			t.Errorf("%s: got %v, want a read-only denial", a, err)
+		}
+	}
+	if p.Label() != "READ-ONLY" {
+		t.Errorf("label is %q", p.Label())
+	}
+}
+
+func TestNarrow(t *testing.T) {
+	system := &Policy{Default: &Rule{Actions: []string{"up", "down", "edit"}, Interfaces: []string{"wg*"}}}
+	flag := &Policy{Default: &Rule{Actions: []string{"*"}, Interfaces: []string{"wg0", "tun0"}}}
+	for _, tt := range []struct {
+		name   string
+		policy *Policy
+		action Action
+		iface  string
+		want   bool
+	}{
+		{"no policies", (*Policy)(nil).Narrow(nil), Rotate, "wg0", true},
+		{"system only", system.Narrow(nil), Edit, "wg1", true},
+		{"flag only", (*Policy)(nil).Narrow(flag), Rotate, "tun0", true},
+		{"both allow", system.Narrow(flag), Up, "wg0", true},
+		{"flag cannot add actions", system.Narrow(flag), Rotate, "wg0", false},
+		{"flag cannot add interfaces", system.Narrow(flag), Up, "tun0", false},
+		{"flag narrows interfaces", system.Narrow(flag), Up, "wg1", false},
+		{"either way round", flag.Narrow(system), Rotate, "wg0", false},
+		{"read-only flag", system.Narrow(ReadOnlyPolicy()), Up, "wg0", false},
+		{"read-only system", ReadOnlyPolicy().Narrow(flag), Up, "wg0", false},
+		{"narrowed twice", system.Narrow(flag).Narrow(&Policy{Default: &Rule{Actions: []string{"down"}, Interfaces: []string{"*"}}}), Down, "wg0", true},
+		{"narrowed twice, dropped", system.Narrow(flag).Narrow(&Policy{Default: &Rule{Actions: []string{"down"}, Interfaces: []string{"*"}}}), Up, "wg0", false},
+	} {
+		if got := tt.policy.Check(tt.action, tt.iface) == nil; got != tt.want {
+			t.Errorf("%s: %s %s allowed %v, want %v", tt.name, tt.action, tt.iface, got, tt.want)
+		}
+	}
+	if n := system.Narrow(flag); n.Permits(Rotate) || !n.Permits(Edit) {
+		t.Error("narrowed policy permits rotate or not edit")
+	}
+}
@@ -0,0 +1,189 @@
+// Package settings reads the configuration of the app itself, as opposed
+// to that of WireGuard. Command line flags override what it says.
+package settings
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"wireguard-tui/internal/monitor"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SystemPath is read first; the user's file then overrides it key by key
+const SystemPath = "/etc/wireguard-tui/config.toml"
+
+// privilegedKeys decide what runs as root, such as which files wg-quick
+// gets with their PostUp commands, so a process running as root only
+// takes them from files that only root can change
+var privilegedKeys = []string{"config_paths", "backend", "helper_socket", "daemon_socket"}
+
+// geteuid is os.Geteuid, replaced by tests to act as root
+var geteuid = os.Geteuid
+
+// Backends the TUI can use
+var Backends = []string{"auto", "local", "helper", "sudo-helper", "mock"}
+
+// Settings is the config file. Zero values mean the built-in default.
+type Settings struct {
+	// Refresh is how often interfaces are polled
+	Refresh time.Duration `toml:"refresh"`
+	// Theme is the name of the theme to start with
+	Theme string `toml:"theme"`
+	// ConfigPaths are globs for wg-quick configs, first match of a name
+	// winning
+	ConfigPaths []string `toml:"config_paths"`
+	// Backend is one of Backends; auto uses the system helper when not
+	// root and it runs
+	Backend      string `toml:"backend"`
+	HelperSocket string `toml:"helper_socket"`
+	DaemonSocket string `toml:"daemon_socket"`
+	// Columns of the interface list, in order, with optional widths
+	Columns      []string       `toml:"columns"`
+	ColumnWidths map[string]int `toml:"column_widths"`
+	// Units is "iec" (1.5K) or "si" (1.5kB)
+	Units string `toml:"units"`
+	// Plain lays the screen out for screen readers
+	Plain  bool   `toml:"plain"`
+	Alerts Alerts `toml:"alerts"`
+}
+
+// Alerts decides what raises an alert
+type Alerts struct {
+	// StaleAfter is how old a handshake may get before the peer is
+	// reported stale
+	StaleAfter time.Duration `toml:"stale_after"`
+	// Events are the kinds of events shown as alerts
+	Events []string `toml:"events"`
+}
+
+// UserPath is the config file of the person running the app, looking
+// through sudo so their own file still applies
+func UserPath() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		if u, err := user.Lookup(name); err == nil {
+			return filepath.Join(u.HomeDir, ".config", "wireguard-tui", "config.toml")
+		}
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "wireguard-tui", "config.toml")
+}
+
+// ThemeDirs hold theme files, the user's last so theirs win when two
+// themes share a name
+func ThemeDirs() []string {
+	dirs := []string{filepath.Join(filepath.Dir(SystemPath), "themes")}
+	if path := UserPath(); path != "" {
+		dirs = append(dirs, filepath.Join(filepath.Dir(path), "themes"))
+	}
+	return dirs
+}
+
+// Load reads SystemPath and UserPath, either of which may be missing
+func Load() (*Settings, error) {
+	return load(SystemPath, UserPath())
+}
+
+// LoadSystem reads only SystemPath, for processes that act for others,
+// such as the helper
+func LoadSystem() (*Settings, error) {
+	return load(SystemPath)
+}
+
+func load(paths ...string) (*Settings, error) {
+	s := &Settings{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := s.read(path); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// LoadFile reads only the given file, which has to exist
+func LoadFile(path string) (*Settings, error) {
+	s := &Settings{}
+	if err := s.read(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// read decodes a file over what is already set, then checks the result
+func (s *Settings) read(path string) error {
+	md, err := toml.DecodeFile(path, s)
+	if err != nil {
+		return fmt.Errorf("failed to load settings %s: %v", path, err)
+	}
+	if undec := md.Undecoded(); len(undec) > 0 {
+		return fmt.Errorf("settings %s: unknown key %s", path, undec[0])
+	}
+	if geteuid() == 0 && !rootOwned(path) {
+		for _, key := range privilegedKeys {
+			if md.IsDefined(key) {
+				return fmt.Errorf("settings %s: %s is only read as root from a file owned and only writable by root", path, key)
+			}
+		}
+	}
+	if err := s.check(); err != nil {
+		return fmt.Errorf("settings %s: %v", path, err)
+	}
+	return nil
+}
+
+// rootOwned says whether only root can change the file
+func rootOwned(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Uid == 0 && info.Mode().Perm()&0o022 == 0
+}
+
+func (s *Settings) check() error {
+	if s.Refresh != 0 && s.Refresh < 100*time.Millisecond {
+		return fmt.Errorf("refresh %s is too short", s.Refresh)
+	}
+	if s.Backend != "" && !slices.Contains(Backends, s.Backend) {
+		return fmt.Errorf("unknown backend %q", s.Backend)
+	}
+	for _, glob := range s.ConfigPaths {
+		if _, err := filepath.Match(glob, ""); err != nil || !filepath.IsAbs(glob) {
+			return fmt.Errorf("bad config path %q", glob)
+		}
+	}
+	if s.Units != "" && s.Units != "iec" && s.Units != "si" {
+		return fmt.Errorf("units must be iec or si, not %q", s.Units)
+	}
+	for key, width := range s.ColumnWidths {
+		if width < 3 {
+			return fmt.Errorf("column %s is too narrow", key)
+		}
+	}
+	if s.Alerts.StaleAfter < 0 {
+		return fmt.Errorf("stale_after must not be negative")
+	}
+	kinds := []string{monitor.EventUp, monitor.EventDown, monitor.EventStale, monitor.EventRecovered}
+	for _, kind := range s.Alerts.Events {
+		if !slices.Contains(kinds, kind) {
+			return fmt.Errorf("unknown event %q", kind)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPrivilegedKeys(t *testing.T) {
+	defer func(f func() int) { geteuid = f }(geteuid)
+
+	for _, tt := range []struct {
+		name string
+		text string
+		// root runs the app as root, rootFile has the file owned by root
+		root, rootFile bool
+		mode           os.FileMode
+		refused        string // the key refused, "" when the file is read
+	}{
+		{"config_paths from a user file", `config_paths = ["/srv/wg/*.conf"]`, true, false, 0o600, "config_paths"},
+		{"backend from a user file", `backend = "local"`, true, false, 0o600, "backend"},
+		{"helper_socket from a user file", `helper_socket = "/tmp/helper.sock"`, true, false, 0o600, "helper_socket"},
+		{"daemon_socket from a user file", `daemon_socket = "/tmp/daemon.sock"`, true, false, 0o600, "daemon_socket"},
+		{"among other keys", "theme = \"nord\"\nconfig_paths = [\"/srv/wg/*.conf\"]\nunits = \"si\"", true, false, 0o600, "config_paths"},
+		{"display keys from a user file", "theme = \"nord\"\nunits = \"si\"\nrefresh = \"2s\"", true, false, 0o600, ""},
+		{"not running as root", `config_paths = ["/srv/wg/*.conf"]`, false, false, 0o600, ""},
+		{"root's file", `config_paths = ["/srv/wg/*.conf"]`, true, true, 0o644, ""},
+		{"root's file writable by the group", `config_paths = ["/srv/wg/*.conf"]`, true, true, 0o664, "config_paths"},
+		{"root's file writable by anyone", `backend = "local"`, true, true, 0o646, "backend"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// The file really belongs to whoever runs the test: only
+			// root can make one owned by root, and as root the others
+			// are given away to nobody
+			if os.Geteuid() != 0 && tt.rootFile {
+				t.Skip("needs root to create a file owned by root")
+			}
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.text+"\n"), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if os.Geteuid() == 0 && !tt.rootFile {
+				if err := os.Chown(path, 65534, 65534); err != nil {
+					t.Fatal(err)
+				}
+			}
+			geteuid = func() int {
+				if tt.root {
+					return 0
+				}
+				return 1000
+			}
+
+			_, err := LoadFile(path)
+			switch {
+			case tt.refused == "" && err != nil:
+				t.Errorf("got %v, want the file read", err)
+			case tt.refused != "" && (err == nil || !strings.Contains(err.Error(), ": "+tt.refused+" is only read as root")):
+				t.Errorf("got %v, want %s refused", err, tt.refused)
+			}
+		})
+	}
+}
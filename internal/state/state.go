@@ -0,0 +1,41 @@
+// Package state locates the directory wireguard-tui keeps its own data in
+// and identifies who is driving the current session.
+package state
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// Dir returns the directory for backups, reservations and other
+// persistent app data. Root uses /var/lib so every admin on the box shares
+// it; everyone else gets an XDG state dir.
+func Dir() string {
+	if os.Geteuid() == 0 {
+		return "/var/lib/wireguard-tui"
+	}
+	if d := os.Getenv("XDG_STATE_HOME"); d != "" {
+		return filepath.Join(d, "wireguard-tui")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "wireguard-tui")
+	}
+	return filepath.Join(os.TempDir(), "wireguard-tui")
+}
+
+// Invoker returns the login name of the person running the app, looking
+// through sudo so actions are attributed to a human instead of root.
+// Anyone can set SUDO_USER, so it only counts when we do run as root.
+func Invoker() string {
+	if u := os.Getenv("SUDO_USER"); u != "" && os.Geteuid() == 0 {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return "unknown"
+}
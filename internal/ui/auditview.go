@@ -0,0 +1,203 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"wireguard-tui/internal/audit"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// auditLimit is how many of the most recent records the viewer loads
+const auditLimit = 500
+
+// auditView browses the audit log, optionally only for one interface
+type auditView struct {
+	records []audit.Record
+	iface   string
+	host    string
+	only    bool
+	cursor  int
+	err     error
+}
+
+type auditLoadedMsg struct {
+	records []audit.Record
+	err     error
+}
+
+func (m Model) loadAuditCmd() tea.Cmd {
+	return func() tea.Msg {
+		records, err := m.audit.Read(auditLimit)
+		return auditLoadedMsg{records, err}
+	}
+}
+
+func (v *auditView) visible() []audit.Record {
+	if !v.only {
+		return v.records
+	}
+	var out []audit.Record
+	for _, r := range v.records {
+		if r.Interface == v.iface && r.Host == v.host {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (m Model) updateAudit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := m.auditView
+	n := len(v.visible())
+	switch msg.String() {
+	case "esc", "q", "a":
+		m.auditView = nil
+	case "up", "k":
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.cursor < n-1 {
+			v.cursor++
+		}
+	case "pgup":
+		v.cursor -= 10
+		if v.cursor < 0 {
+			v.cursor = 0
+		}
+	case "pgdown":
+		v.cursor += 10
+		if v.cursor > n-1 {
+			v.cursor = max(n-1, 0)
+		}
+	case "tab":
+		if v.iface != "" {
+			v.only = !v.only
+			v.cursor = 0
+		}
+	case "r":
+		return m, m.loadAuditCmd()
+	}
+	return m, nil
+}
+
+func (m Model) renderAuditDialog(width, height int, theme Theme) string {
+	v := m.auditView
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sLabel := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sSel := theme.fill(theme.SelectedFg, theme.SelectedBg)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sBad := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+
+	boxWidth := width - 4
+	if boxWidth > 110 {
+		boxWidth = 110
+	}
+	inner := boxWidth - 6
+
+	if m.audit == nil {
+		return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left,
+			sTitle.Render("Audit log"), "",
+			sDim.Render("Audit logging is off."), "",
+			sKey.Render("Esc")+" Close"))
+	}
+
+	scope := "all interfaces"
+	if v.only {
+		scope = v.iface
+		if v.host != "" {
+			scope = v.host + ":" + v.iface
+		}
+	}
+	lines := []string{sTitle.Render("Audit log, "+scope) + sDim.Render("  "+m.audit.Path), ""}
+
+	records := v.visible()
+	if v.err != nil {
+		lines = append(lines, sBad.Render(truncate(v.err.Error(), inner)))
+	} else if len(records) == 0 {
+		lines = append(lines, sDim.Render("Nothing recorded yet"))
+	}
+
+	// Half the screen for the list, the rest for the selected record
+	listRows := (height - 16) / 2
+	if listRows < 3 {
+		listRows = 3
+	}
+	start := 0
+	if v.cursor >= listRows {
+		start = v.cursor - listRows + 1
+	}
+	for i := start; i < len(records) && i < start+listRows; i++ {
+		r := records[i]
+		outcome := "ok"
+		if !r.OK() {
+			outcome = "FAILED"
+		}
+		row := fmt.Sprintf("%s  %-10s %-10s %-18s %s", r.Time.Local().Format("2006-01-02 15:04:05"),
+			truncate(r.User, 10), truncate(where(r), 10), truncate(r.Action, 18), outcome)
+		row = truncate(row, inner)
+		switch {
+		case i == v.cursor:
+			lines = append(lines, sSel.Render(row+strings.Repeat(" ", inner-lipgloss.Width(row))))
+		case !r.OK():
+			lines = append(lines, sBad.Render(row))
+		default:
+			lines = append(lines, sValue.Render(row))
+		}
+	}
+
+	if v.cursor < len(records) {
+		r := records[v.cursor]
+		lines = append(lines, "")
+		if !r.OK() {
+			lines = append(lines, sLabel.Render("Error: ")+sBad.Render(truncate(r.Error, inner-7)))
+		}
+		keys := make([]string, 0, len(r.Params))
+		for k := range r.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		detailRows := height - 16 - listRows
+		for _, k := range keys {
+			if changes, ok := r.Params[k].([]any); ok {
+				lines = append(lines, sLabel.Render(k+":"))
+				for j, c := range changes {
+					if j >= detailRows {
+						lines = append(lines, sDim.Render(fmt.Sprintf("  … %d more", len(changes)-j)))
+						break
+					}
+					lines = append(lines, sValue.Render(truncate(fmt.Sprintf("  %v", c), inner)))
+				}
+				continue
+			}
+			lines = append(lines, sLabel.Render(k+": ")+sValue.Render(truncate(fmt.Sprint(r.Params[k]), inner-len(k)-2)))
+		}
+	}
+
+	lines = append(lines, "")
+	actions := []string{sKey.Render("↑↓") + " Select"}
+	if v.iface != "" {
+		label := " Only " + v.iface
+		if v.only {
+			label = " All interfaces"
+		}
+		actions = append(actions, sKey.Render("Tab")+label)
+	}
+	actions = append(actions, sKey.Render("R")+" Reload", sKey.Render("Esc")+" Close")
+	lines = append(lines, strings.Join(actions, "  "))
+
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// where is the interface of a record, with the host if it was remote
+func where(r audit.Record) string {
+	if r.Host == "" {
+		return r.Interface
+	}
+	return r.Host + ":" + r.Interface
+}
@@ -0,0 +1,285 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// bulkAction is an operation that can be run on every tagged interface
+type bulkAction struct {
+	key   string
+	label string
+}
+
+var bulkActions = []bulkAction{
+	{"u", "Bring up"},
+	{"d", "Bring down"},
+	{"r", "Restart"},
+	{"l", "Reload (syncconf)"},
+	{"e", "Export configs"},
+}
+
+type bulkResult struct {
+	name   string
+	err    error
+	detail string
+}
+
+// bulkRun executes one action on a list of interfaces, one at a time, and
+// keeps every outcome so failures are not lost behind the last error
+type bulkRun struct {
+	action  bulkAction
+	names   []string
+	results []bulkResult
+	dir     string // export destination
+	done    bool
+}
+
+// bulkView is the action menu, and then the progress and result dialog
+type bulkView struct {
+	// names are the tagged interfaces, or the selected one if none are
+	names []string
+	run   *bulkRun
+}
+
+type bulkStepMsg struct {
+	run    *bulkRun
+	result bulkResult
+}
+
+func (m Model) taggedNames() []string {
+	var names []string
+	for _, iface := range m.interfaces {
+		if m.tagged[iface.Name] {
+			names = append(names, iface.Name)
+		}
+	}
+	return names
+}
+
+// tagToggle tags or untags the interface under the cursor
+func (m *Model) tagToggle() {
+	filtered := m.getFilteredInterfaces()
+	if m.cursor >= len(filtered) {
+		return
+	}
+	name := filtered[m.cursor].Name
+	if m.tagged[name] {
+		delete(m.tagged, name)
+	} else {
+		m.tagged[name] = true
+	}
+	if m.cursor < len(filtered)-1 {
+		m.cursor++
+	}
+}
+
+// tagFiltered tags every interface matching the current filter
+func (m *Model) tagFiltered() {
+	for _, iface := range m.getFilteredInterfaces() {
+		m.tagged[iface.Name] = true
+	}
+}
+
+func (m Model) startBulk(action bulkAction) (Model, tea.Cmd) {
+	run := &bulkRun{action: action, names: m.bulk.names}
+	if action.key == "e" {
+		run.dir = filepath.Join(m.stateDir, "exports", time.Now().Format("20060102-150405"))
+	}
+	m.bulk.run = run
+	return m, m.bulkStepCmd(run)
+}
+
+// bulkStepCmd runs the action on the next interface in the queue
+func (m Model) bulkStepCmd(run *bulkRun) tea.Cmd {
+	i := len(run.results)
+	if i >= len(run.names) {
+		return nil
+	}
+	name := run.names[i]
+	status := wg.InterfaceDown
+	for _, iface := range m.interfaces {
+		if iface.Name == name {
+			status = iface.Status
+		}
+	}
+	skip := func(detail string) tea.Cmd {
+		return func() tea.Msg {
+			return bulkStepMsg{run: run, result: bulkResult{name: name, detail: detail}}
+		}
+	}
+
+	// Interface changes go through the operation manager so they queue
+	// behind anything else already running on the same interface
+	if status == wg.InterfaceUnknown && run.action.key != "e" {
+		return skip("state unknown, wg failed")
+	}
+	var op *operation
+	switch run.action.key {
+	case "u":
+		if status == wg.InterfaceUp {
+			return skip("already up")
+		}
+		op = m.toggleOp(name, true)
+	case "d":
+		if status != wg.InterfaceUp {
+			return skip("already down")
+		}
+		op = m.toggleOp(name, false)
+		m.skipLockout(op)
+	case "r":
+		op = m.restartOp(name, status == wg.InterfaceUp)
+		if status == wg.InterfaceUp {
+			m.skipLockout(op)
+		}
+	case "l":
+		if status != wg.InterfaceUp {
+			return skip("down, nothing to reload")
+		}
+		op = m.reloadOp(name)
+	case "e":
+		if err := m.policy.Check(policy.Secrets, name); err != nil {
+			return func() tea.Msg {
+				return bulkStepMsg{run: run, result: bulkResult{name: name, err: err}}
+			}
+		}
+		return func() tea.Msg {
+			res := bulkResult{name: name}
+			res.err = exportConfig(m.client, name, run.dir)
+			if res.err == nil {
+				res.detail = filepath.Join(run.dir, name+".conf")
+			}
+			return bulkStepMsg{run: run, result: res}
+		}
+	}
+	op.notify = func(err error) tea.Msg {
+		return bulkStepMsg{run: run, result: bulkResult{name: name, err: err}}
+	}
+	return m.submit(op)
+}
+
+func exportConfig(client wg.Client, name, dir string) error {
+	data, err := client.ReadConfig(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".conf"), data, 0600)
+}
+
+func (m Model) updateBulkStep(msg bulkStepMsg) (tea.Model, tea.Cmd) {
+	run := msg.run
+	run.results = append(run.results, msg.result)
+	if len(run.results) < len(run.names) {
+		return m, m.bulkStepCmd(run)
+	}
+	run.done = true
+	return m, m.refresh()
+}
+
+func (m Model) updateBulk(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	run := m.bulk.run
+	key := msg.String()
+	if run == nil {
+		if key == "esc" || key == "q" || key == "f9" || key == "b" {
+			m.bulk = nil
+			return m, nil
+		}
+		for _, a := range bulkActions {
+			if a.key == key && m.bulkPermitted(a.key) {
+				return m.startBulk(a)
+			}
+		}
+		return m, nil
+	}
+	if run.done {
+		m.bulk = nil
+		// Keep failed interfaces tagged so the action can be retried
+		for k := range m.tagged {
+			delete(m.tagged, k)
+		}
+		for _, r := range run.results {
+			if r.err != nil {
+				m.tagged[r.name] = true
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Model) renderBulkDialog(width, height int, theme Theme) string {
+	run := m.bulk.run
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sOK := lipgloss.NewStyle().Foreground(theme.GoodFg).Bold(true)
+	sBad := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+
+	boxWidth := width - 8
+	if boxWidth > 90 {
+		boxWidth = 90
+	}
+	inner := boxWidth - 6
+
+	var lines []string
+	if run == nil {
+		names := m.bulk.names
+		lines = append(lines, sTitle.Render(fmt.Sprintf("Bulk action on %d interface(s)", len(names))), "")
+		lines = append(lines, sValue.Render(truncate(strings.Join(names, ", "), inner)), "")
+		for _, a := range bulkActions {
+			if m.bulkPermitted(a.key) {
+				lines = append(lines, sKey.Render(strings.ToUpper(a.key))+" "+a.label)
+			}
+		}
+		lines = append(lines, "", sKey.Render("Esc")+" Cancel")
+		return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	}
+
+	failed := 0
+	for _, r := range run.results {
+		if r.err != nil {
+			failed++
+		}
+	}
+	title := fmt.Sprintf("%s: %d/%d done", run.action.label, len(run.results), len(run.names))
+	if run.done {
+		title = fmt.Sprintf("%s: %d succeeded, %d failed", run.action.label, len(run.results)-failed, failed)
+	}
+	lines = append(lines, sTitle.Render(title), "")
+
+	for i, name := range run.names {
+		var status string
+		switch {
+		case i < len(run.results) && run.results[i].err != nil:
+			status = sBad.Render("✗ ") + sValue.Render(truncate(run.results[i].err.Error(), inner-16))
+		case i < len(run.results):
+			status = sOK.Render("✓ ") + sDim.Render(truncate(run.results[i].detail, inner-16))
+		case i == len(run.results):
+			status = sDim.Render("running…")
+		default:
+			status = sDim.Render("queued")
+		}
+		lines = append(lines, sValue.Render(fmt.Sprintf("%-12s ", truncate(name, 12)))+status)
+	}
+	if run.done {
+		lines = append(lines, "")
+		if failed > 0 {
+			lines = append(lines, sDim.Render("Failed interfaces stay tagged so you can retry."))
+		}
+		lines = append(lines, sDim.Render("Press any key to close"))
+	}
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
@@ -0,0 +1,69 @@
+package ui
+
+// Units for byte counts
+const (
+	UnitsIEC = "iec" // powers of 1024: 1.5K, 3.2M
+	UnitsSI  = "si"  // powers of 1000: 1.5kB, 3.2MB
+)
+
+// column is a column of the interface list
+type column struct {
+	key   string
+	title string
+	width int
+}
+
+// defaultColumns are the columns shown unless configured otherwise. The
+// last one is as wide as what is left.
+var defaultColumns = []column{
+	{"name", "Interface", 12},
+	{"status", "Status", 14},
+	{"port", "Port", 7},
+	{"peers", "Peers", 10},
+	{"transfer", "Transfer (Total)", 22},
+	{"active", "Active (Latest)", 8},
+}
+
+// ColumnKeys lists the columns that can be configured
+func ColumnKeys() []string {
+	var keys []string
+	for _, c := range defaultColumns {
+		keys = append(keys, c.key)
+	}
+	return keys
+}
+
+// newColumns picks and sizes the columns; unknown keys are skipped
+func newColumns(keys []string, widths map[string]int) []column {
+	if len(keys) == 0 {
+		keys = ColumnKeys()
+	}
+	var cols []column
+	for _, key := range keys {
+		for _, c := range defaultColumns {
+			if c.key == key {
+				if w := widths[key]; w > 0 {
+					c.width = w
+				}
+				cols = append(cols, c)
+			}
+		}
+	}
+	return cols
+}
+
+// columnWidths sizes the columns for the screen, giving the last one the
+// rest of the width, or its own if that is more
+func (m Model) columnWidths(width int) map[string]int {
+	widths := make(map[string]int)
+	rest := width - 2
+	for i, c := range m.columns {
+		if i == len(m.columns)-1 {
+			widths[c.key] = max(rest, c.width)
+			break
+		}
+		widths[c.key] = c.width
+		rest -= c.width
+	}
+	return widths
+}
@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pane selects what the lower half of the screen shows
+type pane int
+
+const (
+	paneDetails pane = iota
+	paneConfig
+	paneLog
+)
+
+// configView holds the config file currently shown in paneConfig.
+// Secrets stay masked until reveal is set, and reveal is reset whenever
+// another interface is selected.
+type configView struct {
+	name   string
+	data   []byte
+	err    error
+	scroll int
+	reveal bool
+}
+
+type configLoadedMsg struct {
+	name string
+	data []byte
+	err  error
+}
+
+// secretKeys are masked in the config viewer
+var secretKeys = map[string]bool{
+	"privatekey":   true,
+	"presharedkey": true,
+}
+
+// configPath names the config of an interface as the client last listed it
+func (m Model) configPath(name string) string {
+	for _, iface := range m.interfaces {
+		if iface.Name == name {
+			return iface.ConfigPath()
+		}
+	}
+	return wg.Interface{Name: name}.ConfigPath()
+}
+
+func (m Model) loadConfigCmd(name string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := m.client.ReadConfig(context.Background(), name)
+		return configLoadedMsg{name: name, data: data, err: err}
+	}
+}
+
+// syncConfigView reloads the viewer when the selection moved to another interface
+func (m *Model) syncConfigView() tea.Cmd {
+	if m.pane != paneConfig {
+		return nil
+	}
+	filtered := m.getFilteredInterfaces()
+	if m.cursor >= len(filtered) {
+		return nil
+	}
+	name := filtered[m.cursor].Name
+	if m.cfgView.name == name {
+		return nil
+	}
+	m.cfgView = configView{name: name}
+	return m.loadConfigCmd(name)
+}
+
+func (m Model) renderConfigPanel(iface wg.Interface, width, height int, theme Theme) string {
+	sPanel := lipgloss.NewStyle().
+		Border(m.border(lipgloss.RoundedBorder())).
+		BorderForeground(theme.ColumnHeaderFg).
+		Padding(0, 1).
+		Width(width - 2).
+		Height(height - 2)
+	sLabel := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sError := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+
+	v := m.cfgView
+	secrets := "secrets hidden, S to reveal"
+	switch {
+	case m.policy.Check(policy.Secrets, iface.Name) != nil:
+		secrets = "secrets withheld by the policy"
+	case v.reveal:
+		secrets = sError.Render("SECRETS VISIBLE") + sDim.Render(", S to hide")
+	}
+	title := sLabel.Render(iface.ConfigPath()) + sDim.Render("  ["+secrets+"]  PgUp/PgDn scroll")
+
+	inner := width - 6
+	visible := height - 3
+	if visible < 1 {
+		visible = 1
+	}
+
+	var lines []string
+	switch {
+	case v.name != iface.Name:
+		lines = append(lines, sDim.Render("Loading…"))
+	case v.err != nil:
+		lines = append(lines, sError.Render(truncate(v.err.Error(), inner)))
+	default:
+		src := strings.Split(strings.TrimSuffix(string(v.data), "\n"), "\n")
+		numWidth := len(fmt.Sprintf("%d", len(src)))
+		start := v.scroll
+		if start > len(src)-visible {
+			start = len(src) - visible
+		}
+		if start < 0 {
+			start = 0
+		}
+		for i := start; i < len(src) && i < start+visible; i++ {
+			num := sDim.Render(fmt.Sprintf("%*d │ ", numWidth, i+1))
+			lines = append(lines, num+highlightConfigLine(src[i], v.reveal, inner-numWidth-3, theme))
+		}
+	}
+
+	return sPanel.Render(title + "\n" + strings.Join(lines, "\n"))
+}
+
+// highlightConfigLine colours one line of a wg-quick config using the theme
+func highlightConfigLine(line string, reveal bool, maxLen int, theme Theme) string {
+	sSection := lipgloss.NewStyle().Foreground(theme.HeaderBg).Bold(true)
+	sKey := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sSecret := lipgloss.NewStyle().Foreground(theme.KeyBg)
+
+	line = truncate(line, maxLen)
+	body, comment := line, ""
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		body, comment = line[:i], line[i:]
+	}
+
+	var out string
+	trimmed := strings.TrimSpace(body)
+	switch {
+	case trimmed == "":
+		out = body
+	case strings.HasPrefix(trimmed, "["):
+		out = sSection.Render(body)
+	default:
+		key, value, ok := strings.Cut(body, "=")
+		if !ok {
+			out = sValue.Render(body)
+			break
+		}
+		if secretKeys[strings.ToLower(strings.TrimSpace(key))] && !reveal && strings.TrimSpace(value) != "" {
+			out = sKey.Render(key) + sDim.Render("=") + sSecret.Render(" ••••••••••••")
+			break
+		}
+		out = sKey.Render(key) + sDim.Render("=") + sValue.Render(value)
+	}
+	if comment != "" {
+		out += sDim.Render(comment)
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"wireguard-tui/internal/doctor"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// doctorView shows the preflight checks of the current host
+type doctorView struct {
+	checks  []doctor.Check
+	running bool
+	scroll  int
+}
+
+type doctorMsg struct {
+	host   int
+	checks []doctor.Check
+}
+
+func (m Model) doctorCmd() tea.Cmd {
+	m.doctor.running = true
+	env := doctor.Env{Runner: m.hosts[m.hostIndex].Runner, Client: m.client}
+	host := m.hostIndex
+	return func() tea.Msg {
+		return doctorMsg{host, env.Run(context.Background())}
+	}
+}
+
+func (m Model) updateDoctor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := m.doctor
+	switch msg.String() {
+	case "esc", "q", "D":
+		m.doctor = nil
+	case "up", "k":
+		if v.scroll > 0 {
+			v.scroll--
+		}
+	case "down", "j":
+		if v.scroll < len(v.checks)-1 {
+			v.scroll++
+		}
+	case "r":
+		if !v.running {
+			return m, m.doctorCmd()
+		}
+	}
+	return m, nil
+}
+
+func (m Model) updateDoctorDone(msg doctorMsg) (tea.Model, tea.Cmd) {
+	if m.doctor == nil || msg.host != m.hostIndex {
+		return m, nil
+	}
+	m.doctor.checks = msg.checks
+	m.doctor.running = false
+	return m, nil
+}
+
+func (m Model) renderDoctorDialog(width, height int, theme Theme) string {
+	v := m.doctor
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	statusStyles := map[doctor.Status]lipgloss.Style{
+		doctor.Pass: lipgloss.NewStyle().Foreground(theme.GoodFg).Bold(true),
+		doctor.Warn: lipgloss.NewStyle().Foreground(theme.WarnFg).Bold(true),
+		doctor.Fail: lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true),
+	}
+
+	boxWidth := width - 4
+	if boxWidth > 100 {
+		boxWidth = 100
+	}
+	inner := boxWidth - 6
+
+	title := sTitle.Render("Doctor, " + m.hostLabel())
+	if v.running {
+		title += sDim.Render("  checking…")
+	} else if n := doctor.Failed(v.checks); n > 0 {
+		title += statusStyles[doctor.Fail].Render(fmt.Sprintf("  %d failed", n))
+	}
+	lines := []string{title, ""}
+	if v.checks == nil {
+		lines = append(lines, sDim.Render("Running checks"))
+	}
+
+	nameWidth := 0
+	for _, c := range v.checks {
+		nameWidth = max(nameWidth, len(c.Name))
+	}
+	// Hints are what the screen is for, so they wrap rather than truncate
+	sHint := sDim.PaddingLeft(8 + nameWidth).Width(inner)
+	// Scroll by check, keeping each hint with its check
+	var body []string
+	for _, c := range v.checks[min(v.scroll, len(v.checks)):] {
+		body = append(body, statusStyles[c.Status].Render(c.Status.String())+"  "+
+			sValue.Render(truncate(fmt.Sprintf("%-*s  %s", nameWidth, c.Name, c.Detail), inner-6)))
+		if c.Hint != "" {
+			body = append(body, strings.Split(sHint.Render("→ "+c.Hint), "\n")...)
+		}
+	}
+	if rows := max(height-10, 3); len(body) > rows {
+		body = body[:rows]
+	}
+	lines = append(lines, body...)
+
+	actions := []string{sKey.Render("↑↓") + " Scroll", sKey.Render("R") + " Check again", sKey.Render("Esc") + " Close"}
+	lines = append(lines, "", strings.Join(actions, "  "))
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
@@ -0,0 +1,304 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"wireguard-tui/internal/diff"
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// editSession tracks one round-trip through $EDITOR. The user edits a
+// private working copy; /etc/wireguard is only touched once they pick an
+// action in the review dialog, so rolling back just means discarding it.
+type editSession struct {
+	iface    wg.Interface
+	dir      string
+	path     string
+	original []byte
+	edited   []byte
+	diff     []diff.Line
+	invalid  error
+	scroll   int
+	busy     bool
+}
+
+type editReadyMsg struct{ session *editSession }
+
+type editorDoneMsg struct {
+	session *editSession
+	err     error
+}
+
+type editAppliedMsg struct {
+	session *editSession
+	err     error
+}
+
+// editCmd copies the interface config into a temp dir and hands it to the editor
+func (m Model) editCmd(iface wg.Interface) tea.Cmd {
+	return func() tea.Msg {
+		data, err := m.client.ReadConfig(context.Background(), iface.Name)
+		if err != nil {
+			return err
+		}
+		dir, err := os.MkdirTemp("", "wireguard-tui-edit-")
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, iface.Name+".conf")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+		return editReadyMsg{&editSession{iface: iface, dir: dir, path: path, original: data}}
+	}
+}
+
+func runEditor(s *editSession) tea.Cmd {
+	return tea.ExecProcess(editorCommand(s.path), func(err error) tea.Msg {
+		return editorDoneMsg{session: s, err: err}
+	})
+}
+
+// editorCommand honours the same variables as sudoedit, in the same order
+func editorCommand(path string) *exec.Cmd {
+	editor := "vi"
+	for _, env := range []string{"SUDO_EDITOR", "VISUAL", "EDITOR"} {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			editor = v
+			break
+		}
+	}
+	args := strings.Fields(editor)
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// review reloads the working copy after the editor exits and validates it
+func (s *editSession) review() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	s.edited = data
+	s.diff = diff.Context(diff.Text(string(s.original), string(data)), 2)
+	s.scroll = 0
+	s.invalid = nil
+	cfg, err := wg.ParseConfig(data)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	s.invalid = err
+	return nil
+}
+
+func (s *editSession) changed() bool {
+	return !bytes.Equal(s.original, s.edited)
+}
+
+func (s *editSession) close() {
+	os.RemoveAll(s.dir)
+}
+
+// editVerbs describe the apply actions while they run
+var editVerbs = map[string]string{"write": "saving config", "sync": "applying config", "restart": "restarting"}
+
+// applyEditCmd writes the edited config and optionally pushes it to the
+// running interface. If applying fails the previous file is written back.
+// A restart takes the interface down, so it goes past the lockout guard.
+func (m Model) applyEditCmd(s *editSession, action string) tea.Cmd {
+	op := m.editOp(s, action)
+	if action == "restart" {
+		return m.guardCmd(op)
+	}
+	return m.submit(op)
+}
+
+func (m Model) editOp(s *editSession, action string) *operation {
+	name := s.iface.Name
+	return &operation{iface: name, verb: editVerbs[action], run: func(ctx context.Context, out io.Writer) error {
+		if err := m.writeConfig(ctx, name, "edit", s.edited); err != nil {
+			return err
+		}
+
+		var err error
+		// down is set once a restart has taken the interface down, which
+		// a rollback then has to undo as well
+		down := false
+		switch action {
+		case "sync":
+			err = m.client.SyncConfig(ctx, name)
+		case "restart":
+			if err = m.client.ToggleInterface(ctx, name, false, out); err == nil {
+				down = true
+				err = m.client.ToggleInterface(ctx, name, true, out)
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		// A cancelled change is still rolled back
+		if ctx.Err() != nil {
+			err = errCancelled
+		}
+		ctx = context.WithoutCancel(ctx)
+		if rbErr := m.writeConfig(ctx, name, "rollback", s.original); rbErr != nil {
+			return fmt.Errorf("%w (rollback also failed: %v)", err, rbErr)
+		}
+		if down {
+			if upErr := m.client.ToggleInterface(ctx, name, true, out); upErr != nil {
+				return fmt.Errorf("%w (previous config restored, but bringing %s back up failed: %v)", err, name, upErr)
+			}
+			return fmt.Errorf("%w (previous config restored and %s back up)", err, name)
+		}
+		return fmt.Errorf("%w (previous config restored)", err)
+	}, notify: func(err error) tea.Msg {
+		return editAppliedMsg{s, err}
+	}}
+}
+
+func (m Model) updateEdit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s := m.edit
+	if s.busy {
+		if msg.String() == "esc" {
+			m.ops.cancel(s.iface.Name)
+		}
+		return m, nil
+	}
+	up := s.iface.Status == wg.InterfaceUp
+
+	switch msg.String() {
+	case "up", "k":
+		if s.scroll > 0 {
+			s.scroll--
+		}
+		return m, nil
+	case "down", "j":
+		if s.scroll < len(s.diff)-1 {
+			s.scroll++
+		}
+		return m, nil
+	case "e":
+		return m, runEditor(s)
+	case "r", "esc", "q":
+		s.close()
+		m.edit = nil
+		return m, nil
+	}
+
+	if s.invalid != nil || !s.changed() {
+		return m, nil
+	}
+	switch msg.String() {
+	case "a":
+		if up {
+			s.busy = true
+			return m, m.applyEditCmd(s, "sync")
+		}
+	case "s":
+		if up {
+			s.busy = true
+			return m, m.applyEditCmd(s, "restart")
+		}
+	case "w":
+		s.busy = true
+		return m, m.applyEditCmd(s, "write")
+	}
+	return m, nil
+}
+
+func (m Model) renderEditDialog(width, height int, theme Theme) string {
+	s := m.edit
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sAdd := lipgloss.NewStyle().Foreground(theme.GoodFg)
+	sDel := lipgloss.NewStyle().Foreground(theme.BadFg)
+
+	boxWidth := width - 8
+	if boxWidth > 100 {
+		boxWidth = 100
+	}
+	inner := boxWidth - 6
+	bodyHeight := height - 14
+	if bodyHeight < 3 {
+		bodyHeight = 3
+	}
+
+	var lines []string
+	lines = append(lines, sTitle.Render("Edit "+s.iface.ConfigPath()), "")
+
+	var body []string
+	switch {
+	case s.invalid != nil:
+		lines = append(lines, sDel.Bold(true).Render("Validation failed — the file on disk is unchanged"))
+		if errs, ok := s.invalid.(wg.ConfigErrors); ok {
+			for _, e := range errs {
+				body = append(body, sDel.Render("✗ "+truncate(e.Error(), inner-2)))
+			}
+		} else {
+			body = append(body, sDel.Render("✗ "+truncate(s.invalid.Error(), inner-2)))
+		}
+	case !s.changed():
+		lines = append(lines, sDim.Render("No changes"))
+	default:
+		for _, l := range s.diff {
+			text := truncate(l.Text, inner-2)
+			switch l.Op {
+			case diff.Insert:
+				body = append(body, sAdd.Render("+ "+text))
+			case diff.Delete:
+				body = append(body, sDel.Render("- "+text))
+			default:
+				body = append(body, sDim.Render("  "+text))
+			}
+		}
+	}
+
+	if len(body) > 0 {
+		start := s.scroll
+		if start > len(body)-1 {
+			start = len(body) - 1
+		}
+		end := start + bodyHeight
+		if end > len(body) {
+			end = len(body)
+		}
+		lines = append(lines, body[start:end]...)
+		if len(body) > bodyHeight {
+			lines = append(lines, sDim.Render(fmt.Sprintf("(%d-%d of %d, ↑/↓ to scroll)", start+1, end, len(body))))
+		}
+	}
+	lines = append(lines, "")
+
+	var actions []string
+	if s.busy {
+		actions = append(actions, sDim.Render("Applying… Esc to cancel"))
+	} else {
+		if s.invalid == nil && s.changed() {
+			if s.iface.Status == wg.InterfaceUp {
+				actions = append(actions,
+					sKey.Render("A")+" Apply live (syncconf)",
+					sKey.Render("S")+" Save & restart",
+				)
+			}
+			actions = append(actions, sKey.Render("W")+" Save only")
+		}
+		actions = append(actions,
+			sKey.Render("E")+" Edit again",
+			sKey.Render("R")+" Roll back",
+		)
+	}
+	lines = append(lines, strings.Join(actions, "  "))
+
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
@@ -0,0 +1,252 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fleetInterval is how often the fleet view polls every host. Each poll
+// is a few SSH round trips per remote host, so it is slower than the
+// refresh of the current host.
+const fleetInterval = 5 * time.Second
+
+// fleetView lists the interfaces of every host in one table
+type fleetView struct {
+	// hosts holds the last poll of each host, by index into Model.hosts
+	hosts   []fleetHost
+	cursor  int
+	polling bool
+	polled  time.Time
+}
+
+type fleetHost struct {
+	snap *monitor.Snapshot
+	err  error
+}
+
+// fleetRow is one line of the table: an interface, or a host that has
+// none or could not be reached
+type fleetRow struct {
+	host  int
+	iface *wg.Interface
+	peers []wg.Peer
+	err   error
+}
+
+type fleetMsg struct {
+	hosts []fleetHost
+}
+
+// pollFleetCmd polls all hosts at once, so one slow gateway does not hold
+// up the others more than it has to
+func (m Model) pollFleetCmd() tea.Cmd {
+	m.fleet.polling = true
+	hosts := m.hosts
+	return func() tea.Msg {
+		out := make([]fleetHost, len(hosts))
+		var wait sync.WaitGroup
+		for i, h := range hosts {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				out[i].snap, _, out[i].err = h.snapshot(context.Background(), false, 0, 0)
+			}()
+		}
+		wait.Wait()
+		return fleetMsg{out}
+	}
+}
+
+// fleetTick polls again once the last poll is old enough
+func (m Model) fleetTick() tea.Cmd {
+	if m.fleet.polling || time.Since(m.fleet.polled) < fleetInterval {
+		return nil
+	}
+	return m.pollFleetCmd()
+}
+
+func (v *fleetView) rows() []fleetRow {
+	var rows []fleetRow
+	for i, h := range v.hosts {
+		if h.err != nil || h.snap == nil || len(h.snap.Interfaces) == 0 {
+			rows = append(rows, fleetRow{host: i, err: h.err})
+			continue
+		}
+		for j := range h.snap.Interfaces {
+			iface := &h.snap.Interfaces[j]
+			rows = append(rows, fleetRow{host: i, iface: iface, peers: h.snap.Peers[iface.Name]})
+		}
+	}
+	return rows
+}
+
+func (m Model) updateFleet(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := m.fleet
+	rows := v.rows()
+	switch msg.String() {
+	case "esc", "q", "F":
+		m.fleet = nil
+	case "up", "k":
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.cursor < len(rows)-1 {
+			v.cursor++
+		}
+	case "r":
+		if !v.polling {
+			return m, m.pollFleetCmd()
+		}
+	case "enter":
+		if v.cursor >= len(rows) {
+			return m, nil
+		}
+		return m.drillDown(rows[v.cursor])
+	}
+	return m, nil
+}
+
+// drillDown opens the normal interface list of the row's host with its
+// interface selected, starting from what the fleet poll found
+func (m Model) drillDown(row fleetRow) (tea.Model, tea.Cmd) {
+	if row.host != m.hostIndex && !m.switchHost(row.host) {
+		return m, nil
+	}
+	snap := m.fleet.hosts[row.host].snap
+	m.fleet = nil
+	m.filterText = ""
+	m.cursor = 0
+	if m.interfaces == nil && snap != nil {
+		m.interfaces, m.peers = snap.Interfaces, snap.Peers
+	}
+	for i, iface := range m.interfaces {
+		if row.iface != nil && iface.Name == row.iface.Name {
+			m.cursor = i
+		}
+	}
+	cmd := m.syncConfigView()
+	return m, tea.Batch(cmd, m.refresh())
+}
+
+func (m Model) updateFleetPolled(msg fleetMsg) (tea.Model, tea.Cmd) {
+	if m.fleet == nil {
+		return m, nil
+	}
+	m.fleet.hosts = msg.hosts
+	m.fleet.polling = false
+	m.fleet.polled = time.Now()
+	if n := len(m.fleet.rows()); m.fleet.cursor >= n {
+		m.fleet.cursor = max(n-1, 0)
+	}
+	return m, nil
+}
+
+// worstHandshake is the age of the oldest session on an interface, or ""
+// when there is nothing to report. A peer that never shook hands is the
+// worst of all.
+func worstHandshake(peers []wg.Peer) string {
+	var worst time.Duration
+	for _, p := range peers {
+		if p.LatestHandshake.IsZero() {
+			return "Never"
+		}
+		worst = max(worst, time.Since(p.LatestHandshake))
+	}
+	if len(peers) == 0 {
+		return ""
+	}
+	return fmtDur(worst)
+}
+
+func (m Model) renderFleetDialog(width, height int, theme Theme) string {
+	v := m.fleet
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sHead := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sSel := theme.fill(theme.SelectedFg, theme.SelectedBg)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sBad := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+	sUp := lipgloss.NewStyle().Foreground(theme.GoodFg).Bold(true)
+
+	boxWidth := width - 4
+	if boxWidth > 110 {
+		boxWidth = 110
+	}
+	inner := boxWidth - 6
+
+	status := "polling…"
+	if !v.polling && !v.polled.IsZero() {
+		status = "polled " + fmtDur(time.Since(v.polled)) + " ago"
+	}
+	lines := []string{sTitle.Render(fmt.Sprintf("Fleet, %d hosts", len(m.hosts))) + sDim.Render("  "+status), ""}
+	format := "%-16s %-12s %-6s %-6s %-24s %s"
+	lines = append(lines, sHead.Render(truncate(fmt.Sprintf(format, "Host", "Interface", "Status", "Peers", "Transfer", "Worst Handshake"), inner)))
+
+	rows := v.rows()
+	if len(rows) == 0 {
+		lines = append(lines, sDim.Render("Waiting for the first poll"))
+	}
+	listRows := max(height-12, 3)
+	start := 0
+	if v.cursor >= listRows {
+		start = v.cursor - listRows + 1
+	}
+	for i := start; i < len(rows) && i < start+listRows; i++ {
+		r := rows[i]
+		name := m.hosts[r.host].Name
+		if name == "" {
+			name = "local"
+		}
+		var row, state string
+		switch {
+		case r.err != nil:
+			row = fmt.Sprintf("%-16s %s", truncate(name, 16), r.err.Error())
+		case r.iface == nil:
+			row = fmt.Sprintf("%-16s %s", truncate(name, 16), "no interfaces")
+		default:
+			state = r.iface.Status.String()
+			peers, transfer, handshake := "-", "-", "-"
+			if r.iface.Status == wg.InterfaceUp {
+				state = "UP"
+				var rx, tx int64
+				for _, p := range r.peers {
+					rx += p.TransferRx
+					tx += p.TransferTx
+				}
+				peers = fmt.Sprint(len(r.peers))
+				transfer = "Rx:" + m.formatBytes(rx) + " Tx:" + m.formatBytes(tx)
+				if h := worstHandshake(r.peers); h != "" {
+					handshake = h
+				}
+			}
+			row = fmt.Sprintf(format, truncate(name, 16), truncate(r.iface.Name, 12), state, peers, transfer, handshake)
+		}
+		row = truncate(row, inner)
+		switch {
+		case i == v.cursor:
+			lines = append(lines, sSel.Render(row+strings.Repeat(" ", inner-lipgloss.Width(row))))
+		case r.err != nil || state == "DOWN":
+			lines = append(lines, sBad.Render(row))
+		case state == "UP":
+			lines = append(lines, sUp.Render(row))
+		default:
+			lines = append(lines, sValue.Render(row))
+		}
+	}
+
+	actions := []string{sKey.Render("↑↓") + " Select", sKey.Render("Enter") + " Open host",
+		sKey.Render("R") + " Poll now", sKey.Render("Esc") + " Close"}
+	lines = append(lines, "", strings.Join(actions, "  "))
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
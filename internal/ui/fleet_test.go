@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"wireguard-tui/internal/wg"
+)
+
+// fakeHost answers the commands a LinuxClient runs with a canned wg dump
+// and config listing, or fails them all like an unreachable host
+func fakeHost(dump string, configs []string, down error) wg.Client {
+	return &wg.LinuxClient{Runner: wg.RunnerFunc(func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+		if down != nil {
+			io.WriteString(stderr, down.Error()+"\n")
+			return down
+		}
+		switch name {
+		case "wg":
+			io.WriteString(stdout, dump)
+		case "sh":
+			io.WriteString(stdout, strings.Join(configs, "\n"))
+		}
+		return nil
+	})}
+}
+
+func TestFleetRows(t *testing.T) {
+	dump := "wg0\tcHJpdg==\tcHVi\t51820\toff\n" +
+		"wg0\tcGVlcjE=\t(none)\t198.51.100.7:51820\t10.0.0.2/32\t1700000000\t100\t200\t25\n" +
+		"wg0\tcGVlcjI=\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff\n"
+	hosts := []Host{
+		{Name: "gw1", Client: fakeHost(dump, []string{"1.9.1700000000 /etc/wireguard/wg0.conf", "2.9.1700000000 /etc/wireguard/wg1.conf"}, nil)},
+		{Name: "gw2", Client: fakeHost("", nil, errors.New("ssh: connect to host gw2 port 22: Connection refused"))},
+		{Name: "gw3", Client: fakeHost("", nil, nil)},
+	}
+	m := NewModel(nil, Options{StateDir: t.TempDir(), Hosts: hosts})
+	m.fleet = &fleetView{}
+	msg, ok := m.pollFleetCmd()().(fleetMsg)
+	if !ok {
+		t.Fatal("the poll did not return a fleetMsg")
+	}
+	rows := (&fleetView{hosts: msg.hosts}).rows()
+
+	type row struct {
+		host  int
+		iface string
+		peers int
+		err   bool
+	}
+	want := []row{
+		{host: 0, iface: "wg0", peers: 2},
+		{host: 0, iface: "wg1"},
+		{host: 1, err: true},
+		{host: 2},
+	}
+	var got []row
+	for _, r := range rows {
+		g := row{host: r.host, peers: len(r.peers), err: r.err != nil}
+		if r.iface != nil {
+			g.iface = r.iface.Name
+		}
+		got = append(got, g)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got rows %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if rows[0].iface.Status != wg.InterfaceUp || rows[1].iface.Status != wg.InterfaceDown {
+		t.Errorf("wg0 is %v and wg1 %v, want up and down", rows[0].iface.Status, rows[1].iface.Status)
+	}
+	if err := rows[2].err; err == nil || !strings.Contains(err.Error(), "Connection refused") {
+		t.Errorf("gw2 has error %v, want the ssh error", err)
+	}
+}
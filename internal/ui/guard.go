@@ -0,0 +1,255 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lockoutGuard asks for the interface name to be typed before taking down
+// an interface the current session depends on. With the safety timer on,
+// the interface comes back up by itself unless the user confirms in time.
+type lockoutGuard struct {
+	iface string
+	// op takes the interface down once confirmed
+	op      *operation
+	risks   []string
+	typed   string
+	safety  bool
+	timeout time.Duration
+	err     error
+	// Set once the timer is armed and the interface is going down
+	cancel   func() error
+	deadline time.Time
+}
+
+type lockoutCheckMsg struct {
+	op    *operation
+	risks []string
+}
+
+type safetyArmedMsg struct {
+	cancel func() error
+	err    error
+}
+
+type safetyDisarmedMsg struct {
+	iface   string
+	bringUp bool
+	err     error
+}
+
+// downCmd takes an interface down, first checking whether that would cut
+// off the session we are running in
+func (m Model) downCmd(name string) tea.Cmd {
+	return m.guardCmd(m.toggleOp(name, false))
+}
+
+// guardCmd submits op, which takes its interface down on the way, e.g. a
+// restart, behind the same check as downCmd
+func (m Model) guardCmd(op *operation) tea.Cmd {
+	ri, ok := wg.As[wg.RouteInspector](m.client)
+	if !ok {
+		return m.submit(op)
+	}
+	return func() tea.Msg {
+		return lockoutCheckMsg{op: op, risks: wg.LockoutRisks(context.Background(), ri, op.iface)}
+	}
+}
+
+// skipLockout makes op fail rather than take down an interface we might
+// be connected through. There is no room to type a confirmation per
+// interface in a bulk run, so such interfaces are left alone.
+func (m Model) skipLockout(op *operation) {
+	ri, ok := wg.As[wg.RouteInspector](m.client)
+	if !ok {
+		return
+	}
+	run := op.run
+	op.run = func(ctx context.Context, out io.Writer) error {
+		if risks := wg.LockoutRisks(ctx, ri, op.iface); len(risks) > 0 {
+			return fmt.Errorf("skipped, %s; take it down on its own to confirm", risks[0])
+		}
+		return run(ctx, out)
+	}
+}
+
+func (m Model) updateLockoutCheck(msg lockoutCheckMsg) (tea.Model, tea.Cmd) {
+	if len(msg.risks) == 0 {
+		return m, m.submit(msg.op)
+	}
+	_, canTime := wg.As[wg.SafetyTimer](m.client)
+	m.guard = &lockoutGuard{
+		iface:   msg.op.iface,
+		op:      msg.op,
+		risks:   msg.risks,
+		safety:  canTime,
+		timeout: m.safetyTimeout,
+	}
+	return m, nil
+}
+
+func (m Model) armSafetyCmd(name string, after time.Duration) tea.Cmd {
+	st, _ := wg.As[wg.SafetyTimer](m.client)
+	return func() tea.Msg {
+		cancel, err := st.ArmSafetyTimer(context.Background(), name, after)
+		return safetyArmedMsg{cancel: cancel, err: err}
+	}
+}
+
+func disarmSafetyCmd(g *lockoutGuard, bringUp bool) tea.Cmd {
+	return func() tea.Msg {
+		return safetyDisarmedMsg{iface: g.iface, bringUp: bringUp, err: g.cancel()}
+	}
+}
+
+func (m Model) updateGuard(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	g := m.guard
+	if g.cancel != nil {
+		switch msg.String() {
+		case "c":
+			return m, disarmSafetyCmd(g, false)
+		case "u":
+			return m, disarmSafetyCmd(g, true)
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.guard = nil
+		if op := g.op; op.notify != nil {
+			return m, func() tea.Msg { return op.notify(errCancelled) }
+		}
+	case "tab":
+		if _, ok := wg.As[wg.SafetyTimer](m.client); ok {
+			g.safety = !g.safety
+		}
+	case "left":
+		if g.timeout > 15*time.Second {
+			g.timeout -= 15 * time.Second
+		}
+	case "right":
+		g.timeout += 15 * time.Second
+	case "backspace":
+		if len(g.typed) > 0 {
+			g.typed = g.typed[:len(g.typed)-1]
+		}
+	case "enter":
+		if g.typed != g.iface {
+			g.err = fmt.Errorf("type %s exactly to confirm", g.iface)
+			return m, nil
+		}
+		if g.safety {
+			// Arm first: once the interface is down we may never get to
+			return m, m.armSafetyCmd(g.iface, g.timeout)
+		}
+		m.guard = nil
+		return m, m.submit(g.op)
+	default:
+		if msg.Type == tea.KeyRunes {
+			g.typed += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) updateSafetyArmed(msg safetyArmedMsg) (tea.Model, tea.Cmd) {
+	g := m.guard
+	if g == nil {
+		return m, nil
+	}
+	if msg.err != nil {
+		g.err = msg.err
+		return m, nil
+	}
+	g.err = nil
+	g.cancel = msg.cancel
+	g.deadline = time.Now().Add(g.timeout)
+	return m, m.submit(g.op)
+}
+
+func (m Model) updateSafetyDisarmed(msg safetyDisarmedMsg) (tea.Model, tea.Cmd) {
+	m.guard = nil
+	if msg.err != nil {
+		m.ops.toast(msg.err.Error(), true)
+		return m, m.refresh()
+	}
+	if msg.bringUp {
+		return m, m.submit(m.toggleOp(msg.iface, true))
+	}
+	m.ops.toast(msg.iface+" stays down, safety timer disarmed", false)
+	return m, nil
+}
+
+// checkSafetyTimer closes the guard once its timer has fired
+func (m *Model) checkSafetyTimer() {
+	g := m.guard
+	if g == nil || g.cancel == nil || time.Now().Before(g.deadline) {
+		return
+	}
+	m.guard = nil
+	m.ops.toast("Safety timer brought "+g.iface+" back up", false)
+}
+
+func (m Model) renderGuardDialog(width, height int, theme Theme) string {
+	g := m.guard
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sLabel := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sWarn := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+
+	boxWidth := width - 8
+	if boxWidth > 80 {
+		boxWidth = 80
+	}
+	inner := boxWidth - 6
+
+	var lines []string
+	if g.cancel != nil {
+		left := time.Until(g.deadline).Round(time.Second)
+		if left < 0 {
+			left = 0
+		}
+		lines = append(lines,
+			sTitle.Render(g.iface+" is going down with the safety timer armed"), "",
+			sValue.Render(fmt.Sprintf("It comes back up by itself in %s.", left)),
+			sDim.Render("If you can still read this, the connection survived."), "",
+			sKey.Render("C")+" Keep it down  "+sKey.Render("U")+" Bring it back up now")
+		return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	}
+
+	lines = append(lines, sWarn.Render("Taking "+g.iface+" down may lock you out"), "")
+	for _, r := range g.risks {
+		lines = append(lines, sValue.Render(truncate("• "+r, inner)))
+	}
+	lines = append(lines, "")
+
+	safety := "off"
+	if g.safety {
+		safety = fmt.Sprintf("on, bring it back up after %s unless confirmed", g.timeout)
+	}
+	if _, ok := wg.As[wg.SafetyTimer](m.client); ok {
+		lines = append(lines, sLabel.Render("Safety timer: ")+sValue.Render(safety),
+			sDim.Render("Tab toggles, ←/→ change the delay"), "")
+	}
+
+	lines = append(lines, sLabel.Render("Type "+g.iface+" to confirm: ")+sValue.Render(g.typed+"█"))
+	if g.err != nil {
+		lines = append(lines, sWarn.Render(truncate(g.err.Error(), inner)))
+	}
+	lines = append(lines, "", strings.Join([]string{
+		sKey.Render("Enter") + " Take it down",
+		sKey.Render("Esc") + " Cancel",
+	}, "  "))
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
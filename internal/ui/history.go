@@ -0,0 +1,273 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"wireguard-tui/internal/audit"
+	"wireguard-tui/internal/backup"
+	"wireguard-tui/internal/diff"
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// historyView lists the backups of one interface and shows what
+// restoring the selected one would change
+type historyView struct {
+	iface    wg.Interface
+	current  []byte
+	versions []backup.Version
+	cursor   int
+	loadedID string
+	diff     []diff.Line
+	err      error
+	busy     bool
+}
+
+type historyLoadedMsg struct {
+	view *historyView
+	err  error
+}
+
+type historyVersionMsg struct {
+	id   string
+	data []byte
+	err  error
+}
+
+type historyRestoredMsg struct{ err error }
+
+// writeConfig is the only way the UI changes a config file, so that every
+// change is preceded by a snapshot of the previous contents
+func (m Model) writeConfig(ctx context.Context, name, action string, data []byte) error {
+	old, err := m.client.ReadConfig(ctx, name)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot back up %s, not writing it: %v", m.configPath(name), err)
+	}
+	if err == nil {
+		if _, err := m.backups.Snapshot(name, action, old); err != nil {
+			return fmt.Errorf("backup failed, not writing %s: %v", m.configPath(name), err)
+		}
+	}
+	return m.client.WriteConfig(ctx, name, data)
+}
+
+// snapshotSaveConfig backs up configs that wg-quick is about to rewrite
+// on the way down because they set SaveConfig = true
+func (m Model) snapshotSaveConfig(ctx context.Context, name string) error {
+	data, err := m.client.ReadConfig(ctx, name)
+	if err != nil {
+		return nil
+	}
+	cfg, _ := wg.ParseConfig(data)
+	if cfg == nil || cfg.Interface == nil || !strings.EqualFold(cfg.Interface.Get("SaveConfig"), "true") {
+		return nil
+	}
+	if _, err := m.backups.Snapshot(name, "down (SaveConfig)", data); err != nil {
+		return fmt.Errorf("backup failed, not bringing %s down: %v", name, err)
+	}
+	return nil
+}
+
+func (m Model) historyCmd(iface wg.Interface) tea.Cmd {
+	return func() tea.Msg {
+		current, err := m.client.ReadConfig(context.Background(), iface.Name)
+		if err != nil && !os.IsNotExist(err) {
+			return historyLoadedMsg{err: err}
+		}
+		versions, err := m.backups.List(iface.Name)
+		if err != nil {
+			return historyLoadedMsg{err: err}
+		}
+		return historyLoadedMsg{view: &historyView{iface: iface, current: current, versions: versions}}
+	}
+}
+
+func (m Model) loadVersionCmd(v backup.Version) tea.Cmd {
+	return func() tea.Msg {
+		data, err := m.backups.Load(v)
+		return historyVersionMsg{id: v.ID, data: data, err: err}
+	}
+}
+
+func (m Model) restoreCmd(h *historyView, apply bool) tea.Cmd {
+	v := h.versions[h.cursor]
+	name := h.iface.Name
+	return m.submit(&operation{iface: name, verb: "restoring " + v.ID, run: func(ctx context.Context, _ io.Writer) error {
+		data, err := m.backups.Load(v)
+		if err != nil {
+			return err
+		}
+		if err := m.writeConfig(ctx, name, "restore "+v.ID, data); err != nil {
+			return err
+		}
+		if apply {
+			return m.client.SyncConfig(ctx, name)
+		}
+		return nil
+	}, notify: func(err error) tea.Msg {
+		return historyRestoredMsg{err}
+	}})
+}
+
+// selectVersion loads the diff for the version under the cursor
+func (h *historyView) selectVersion(m Model) tea.Cmd {
+	if h.cursor >= len(h.versions) {
+		return nil
+	}
+	h.diff = nil
+	h.err = nil
+	h.loadedID = ""
+	return m.loadVersionCmd(h.versions[h.cursor])
+}
+
+func (m Model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	h := m.history
+	if h.busy {
+		if msg.String() == "esc" {
+			m.ops.cancel(h.iface.Name)
+		}
+		return m, nil
+	}
+	switch msg.String() {
+	case "esc", "q", "h", "f7":
+		m.history = nil
+	case "up", "k":
+		if h.cursor > 0 {
+			h.cursor--
+			return m, h.selectVersion(m)
+		}
+	case "down", "j":
+		if h.cursor < len(h.versions)-1 {
+			h.cursor++
+			return m, h.selectVersion(m)
+		}
+	case "r":
+		if h.loadedID != "" {
+			h.busy = true
+			return m, m.restoreCmd(h, false)
+		}
+	case "a":
+		if h.loadedID != "" && h.iface.Status == wg.InterfaceUp {
+			h.busy = true
+			return m, m.restoreCmd(h, true)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) renderHistoryDialog(width, height int, theme Theme) string {
+	h := m.history
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sSel := theme.fill(theme.SelectedFg, theme.SelectedBg)
+	sNorm := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sAdd := lipgloss.NewStyle().Foreground(theme.GoodFg)
+	sDel := lipgloss.NewStyle().Foreground(theme.BadFg)
+
+	boxWidth := width - 8
+	if boxWidth > 100 {
+		boxWidth = 100
+	}
+	inner := boxWidth - 6
+	listHeight := 6
+	diffHeight := height - listHeight - 14
+	if diffHeight < 3 {
+		diffHeight = 3
+	}
+
+	lines := []string{sTitle.Render(fmt.Sprintf("History of %s (%s)", h.iface.Name, h.iface.ConfigPath())), ""}
+
+	if len(h.versions) == 0 {
+		lines = append(lines, sDim.Render("No backups yet. One is taken before every change made from this app."), "")
+		lines = append(lines, sKey.Render("Esc")+" Close")
+		return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	}
+
+	start := 0
+	if h.cursor >= listHeight {
+		start = h.cursor - listHeight + 1
+	}
+	for i := start; i < len(h.versions) && i < start+listHeight; i++ {
+		v := h.versions[i]
+		row := fmt.Sprintf("%s  %-12s %-24s %6s",
+			v.Time.Local().Format("2006-01-02 15:04:05"),
+			truncate(v.User, 12), truncate(v.Action, 24), m.formatBytes(int64(v.Size)))
+		row = truncate(row, inner)
+		if i == h.cursor {
+			lines = append(lines, sSel.Render(row+strings.Repeat(" ", inner-lipgloss.Width(row))))
+		} else {
+			lines = append(lines, sNorm.Render(row))
+		}
+	}
+	lines = append(lines, sDim.Render(fmt.Sprintf("%d version(s)", len(h.versions))), "")
+
+	lines = append(lines, sTitle.Render("Changes if restored (current → selected):"))
+	switch {
+	case h.err != nil:
+		lines = append(lines, sDel.Render(truncate(h.err.Error(), inner)))
+	case h.loadedID == "":
+		lines = append(lines, sDim.Render("Loading…"))
+	case !diff.Changed(h.diff):
+		lines = append(lines, sDim.Render("Identical to the current file"))
+	default:
+		for i, l := range h.diff {
+			if i == diffHeight {
+				lines = append(lines, sDim.Render(fmt.Sprintf("… %d more line(s)", len(h.diff)-diffHeight)))
+				break
+			}
+			text := l.Text
+			// Backups are read straight from disk, past the client
+			if m.policy.Check(policy.Secrets, h.iface.Name) != nil {
+				text = audit.Redact(text)
+			}
+			text = truncate(text, inner-2)
+			switch l.Op {
+			case diff.Insert:
+				lines = append(lines, sAdd.Render("+ "+text))
+			case diff.Delete:
+				lines = append(lines, sDel.Render("- "+text))
+			default:
+				lines = append(lines, sDim.Render("  "+text))
+			}
+		}
+	}
+	lines = append(lines, "")
+
+	if h.busy {
+		lines = append(lines, sDim.Render("Restoring… Esc to cancel"))
+	} else {
+		actions := []string{sKey.Render("R") + " Restore"}
+		if h.iface.Status == wg.InterfaceUp {
+			actions = append(actions, sKey.Render("A")+" Restore & apply live")
+		}
+		actions = append(actions, sKey.Render("Esc")+" Close")
+		lines = append(lines, strings.Join(actions, "  "))
+	}
+
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// border is the line drawn around dialogs and panels. Plain mode keeps
+// the space but leaves out the box drawing characters.
+func (m Model) border(b lipgloss.Border) lipgloss.Border {
+	if m.plain {
+		return lipgloss.HiddenBorder()
+	}
+	return b
+}
+
+func (m Model) dialogBox(width int, theme Theme) lipgloss.Style {
+	return lipgloss.NewStyle().
+		Border(m.border(lipgloss.DoubleBorder())).
+		BorderForeground(theme.KeyBg).
+		Padding(1, 2).
+		Width(width)
+}
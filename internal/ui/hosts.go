@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"wireguard-tui/internal/backup"
+	"wireguard-tui/internal/ipam"
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/wg"
+)
+
+// Host is a machine whose interfaces the UI manages
+type Host struct {
+	// Name is shown in the header; "local" or "" is this machine
+	Name   string
+	Client wg.Client
+	// Daemon is a collector running on the host, if any
+	Daemon monitor.Source
+	// Collector polls Client when there is no daemon. Defaults to a new one.
+	Collector *monitor.Collector
+	// Runner probes the host for the doctor screen; nil is this machine
+	Runner wg.Runner
+
+	stateDir     string
+	backups      *backup.Store
+	reservations *ipam.ReservationStore
+	cache        *hostCache
+}
+
+func (h *Host) local() bool {
+	return h.Name == "" || h.Name == "local"
+}
+
+// useHost points the model at host i
+func (m *Model) useHost(i int) {
+	h := m.hosts[i]
+	m.hostIndex = i
+	m.client = h.Client
+	m.stateDir = h.stateDir
+	m.backups = h.backups
+	m.reservations = h.reservations
+}
+
+// switchHost moves to host i. What is on screen belongs to the old host,
+// so it is dropped; a switch is refused while operations there are still
+// running.
+func (m *Model) switchHost(i int) bool {
+	if i == m.hostIndex {
+		return false
+	}
+	if len(m.ops.running) > 0 || len(m.ops.queued) > 0 {
+		m.ops.toast(fmt.Sprintf("Wait for the operations on %s to finish", m.hostLabel()), true)
+		return false
+	}
+	m.useHost(i)
+	m.interfaces = nil
+	m.peers = make(map[string][]wg.Peer)
+	m.configs = nil
+	m.pools = nil
+	m.reserved = nil
+	m.conflicts = nil
+	m.traffic = nil
+	m.events = nil
+	m.attached = false
+	m.err = nil
+	m.cursor = 0
+	m.cfgView = configView{}
+	m.logScroll = 0
+	m.ops.logs = make(map[string]*opLog)
+	for name := range m.tagged {
+		delete(m.tagged, name)
+	}
+	return true
+}
+
+// hostLabel names the current host for the header
+func (m Model) hostLabel() string {
+	h := m.hosts[m.hostIndex]
+	name := h.Name
+	if name == "" {
+		name = "local"
+	}
+	if len(m.hosts) < 2 {
+		return name
+	}
+	return fmt.Sprintf("%s %d/%d", name, m.hostIndex+1, len(m.hosts))
+}
+
+// hostCache keeps what the last refresh read and parsed, so a tick only
+// reads the configs whose stamp changed, and parses the configs and
+// reservations that changed since
+type hostCache struct {
+	mu            sync.Mutex
+	reservedStamp string
+	reserved      map[string][]ipam.Reservation
+	configs       map[string]*cachedConfig
+}
+
+type cachedConfig struct {
+	// stamp is the wg.Interface.ConfigStamp data was read at
+	stamp string
+	data  []byte
+	cfg   *wg.Config
+	// poolKey is what the pool was built from besides the config
+	poolKey string
+	pool    *ipam.Pool
+}
+
+func newHostCache() *hostCache {
+	return &hostCache{configs: make(map[string]*cachedConfig)}
+}
+
+// loadReservations rereads the reservations file only when its size or
+// modification time changed
+func (c *hostCache) loadReservations(store *ipam.ReservationStore) (map[string][]ipam.Reservation, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stamp := "none"
+	if info, err := os.Stat(store.Path); err == nil {
+		stamp = fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+	}
+	if stamp != c.reservedStamp || c.reserved == nil {
+		reserved, err := store.Load()
+		if err != nil {
+			return nil, ""
+		}
+		c.reserved, c.reservedStamp = reserved, stamp
+	}
+	return c.reserved, c.reservedStamp
+}
+
+// configData returns the config the interface had last time when its
+// stamp says the file has not changed since
+func (c *hostCache) configData(iface wg.Interface) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.configs[iface.Name]
+	if e == nil || iface.ConfigStamp == "" || e.stamp != iface.ConfigStamp {
+		return nil, false
+	}
+	return e.data, true
+}
+
+// config parses data unless it is what the interface had last time, and
+// builds its pool unless neither the config, the live peers nor the
+// reservations changed
+func (c *hostCache) config(iface wg.Interface, data []byte, live []wg.Peer, reserved []ipam.Reservation, reservedStamp string) (*wg.Config, *ipam.Pool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.configs[iface.Name]
+	if e == nil || !bytes.Equal(e.data, data) {
+		cfg, err := wg.ParseConfig(data)
+		if err != nil {
+			delete(c.configs, iface.Name)
+			return nil, nil
+		}
+		e = &cachedConfig{data: data, cfg: cfg}
+		c.configs[iface.Name] = e
+	}
+	e.stamp = iface.ConfigStamp
+	var key strings.Builder
+	key.WriteString(reservedStamp)
+	for _, p := range live {
+		key.WriteString("\n" + strings.Join(p.AllowedIPs, ","))
+	}
+	if e.pool == nil || e.poolKey != key.String() {
+		pool, err := ipam.NewPool(e.cfg, live, reserved)
+		if err != nil {
+			pool = nil
+		}
+		e.pool, e.poolKey = pool, key.String()
+	}
+	return e.cfg, e.pool
+}
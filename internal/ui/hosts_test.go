@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"context"
+	"testing"
+
+	"wireguard-tui/internal/wg"
+)
+
+// stampedClient gives each config the stamp in stamps, as the listing
+// of a LinuxClient does, and counts the configs read
+type stampedClient struct {
+	*wg.MockClient
+	stamps map[string]string
+	reads  int
+}
+
+func (c *stampedClient) GetInterfaces(ctx context.Context) ([]wg.Interface, error) {
+	ifaces, err := c.MockClient.GetInterfaces(ctx)
+	for i := range ifaces {
+		ifaces[i].ConfigStamp = c.stamps[ifaces[i].Name]
+	}
+	return ifaces, err
+}
+
+func (c *stampedClient) ReadConfig(ctx context.Context, name string) ([]byte, error) {
+	c.reads++
+	return c.MockClient.ReadConfig(ctx, name)
+}
+
+func TestRefreshReadsChangedConfigsOnly(t *testing.T) {
+	c := &stampedClient{MockClient: wg.NewMockClient(), stamps: map[string]string{"wg0": "1.10.100", "wg1": "2.10.100", "wg2": "3.10.100"}}
+	m := NewModel(c, Options{StateDir: t.TempDir()})
+
+	m.refreshData(false)
+	if c.reads != 3 {
+		t.Fatalf("read %d configs at first, want 3", c.reads)
+	}
+	msg := m.refreshData(false).(dataMsg)
+	if c.reads != 3 {
+		t.Errorf("read %d configs again with nothing changed, want none", c.reads-3)
+	}
+	if msg.configs["wg0"] == nil || msg.pools["wg0"] == nil {
+		t.Error("lost the config of wg0 when not reading it")
+	}
+
+	c.stamps["wg1"] = "4.10.101"
+	m.refreshData(false)
+	if c.reads != 4 {
+		t.Errorf("read %d configs after wg1 changed, want 1", c.reads-3)
+	}
+
+	m.refreshData(true)
+	if c.reads != 7 {
+		t.Errorf("read %d configs when asked to, want all 3", c.reads-4)
+	}
+}
@@ -0,0 +1,200 @@
+package ui
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"wireguard-tui/internal/ipam"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ipamView is the address allocation dialog of one interface
+type ipamView struct {
+	iface  string
+	cursor int
+	// noting is set while the user types a note for the reservation of addr
+	noting bool
+	note   string
+	addr   netip.Addr
+}
+
+type reservationMsg struct{ err error }
+
+func (m Model) reserveCmd(iface string, addr netip.Addr, note string) tea.Cmd {
+	return func() tea.Msg {
+		return reservationMsg{m.reservations.Reserve(iface, addr, note)}
+	}
+}
+
+func (m Model) releaseCmd(iface string, addr netip.Addr) tea.Cmd {
+	return func() tea.Msg {
+		return reservationMsg{m.reservations.Release(iface, addr)}
+	}
+}
+
+// proposal is the AllowedIPs value for the next peer, covering every
+// address family the interface has a subnet for
+func proposal(pool *ipam.Pool) string {
+	var parts []string
+	for _, family := range []int{4, 6} {
+		if next, ok := pool.Next(family); ok {
+			parts = append(parts, next.String())
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (m Model) updateIPAM(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := m.ipam
+	if v.noting {
+		switch msg.String() {
+		case "esc":
+			v.noting = false
+		case "enter":
+			v.noting = false
+			return m, m.reserveCmd(v.iface, v.addr, strings.TrimSpace(v.note))
+		case "backspace":
+			if len(v.note) > 0 {
+				v.note = v.note[:len(v.note)-1]
+			}
+		default:
+			if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+				v.note += string(msg.Runes)
+			}
+		}
+		return m, nil
+	}
+
+	reserved := m.reserved[v.iface]
+	switch msg.String() {
+	case "esc", "q", "i", "f8":
+		m.ipam = nil
+	case "up", "k":
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.cursor < len(reserved)-1 {
+			v.cursor++
+		}
+	case "r", "6":
+		pool := m.pools[v.iface]
+		if pool == nil {
+			return m, nil
+		}
+		family := 4
+		if msg.String() == "6" {
+			family = 6
+		}
+		if next, ok := pool.Next(family); ok {
+			v.addr = next.Addr()
+			v.note = ""
+			v.noting = true
+		}
+	case "d", "delete":
+		if v.cursor < len(reserved) {
+			addr := reserved[v.cursor].Addr
+			if v.cursor > 0 && v.cursor == len(reserved)-1 {
+				v.cursor--
+			}
+			return m, m.releaseCmd(v.iface, addr)
+		}
+	}
+	return m, nil
+}
+
+// ipamSummary is the one-line utilization readout for the details panel
+func (m Model) ipamSummary(name string) string {
+	pool := m.pools[name]
+	if pool == nil {
+		return ""
+	}
+	var parts []string
+	for _, u := range pool.Usage() {
+		parts = append(parts, fmt.Sprintf("%s %s", u.Subnet, u))
+	}
+	if next := proposal(pool); next != "" {
+		parts = append(parts, "next "+next)
+	}
+	return strings.Join(parts, "  ")
+}
+
+func (m Model) renderIPAMDialog(width, height int, theme Theme) string {
+	v := m.ipam
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sLabel := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sSel := theme.fill(theme.SelectedFg, theme.SelectedBg)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sAccent := lipgloss.NewStyle().Foreground(theme.HeaderBg).Bold(true)
+
+	boxWidth := width - 8
+	if boxWidth > 90 {
+		boxWidth = 90
+	}
+	inner := boxWidth - 6
+
+	lines := []string{sTitle.Render("Address allocation for " + v.iface), ""}
+
+	pool := m.pools[v.iface]
+	if pool == nil {
+		lines = append(lines,
+			sDim.Render("No usable Address in "+m.configPath(v.iface)),
+			"",
+			sKey.Render("Esc")+" Close")
+		return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	}
+
+	for _, u := range pool.Usage() {
+		lines = append(lines, sLabel.Render(fmt.Sprintf("%-22s", u.Subnet.String()))+sValue.Render(u.String()))
+	}
+	lines = append(lines, "")
+	if next := proposal(pool); next != "" {
+		lines = append(lines,
+			sLabel.Render("Next free for a new peer:"),
+			sAccent.Render("  AllowedIPs = "+next))
+	} else {
+		lines = append(lines, sLabel.Render("No free addresses left"))
+	}
+	lines = append(lines, "")
+
+	reserved := m.reserved[v.iface]
+	lines = append(lines, sLabel.Render(fmt.Sprintf("Reservations (%d):", len(reserved))))
+	if len(reserved) == 0 {
+		lines = append(lines, sDim.Render("  none"))
+	}
+	for i, r := range reserved {
+		row := fmt.Sprintf("  %-20s %-10s %s  %s", r.Addr, truncate(r.User, 10), r.Time.Local().Format("2006-01-02"), r.Note)
+		row = truncate(row, inner)
+		if i == v.cursor {
+			lines = append(lines, sSel.Render(row+strings.Repeat(" ", inner-lipgloss.Width(row))))
+		} else {
+			lines = append(lines, sValue.Render(row))
+		}
+	}
+	lines = append(lines, "")
+
+	if v.noting {
+		lines = append(lines, sLabel.Render("Note for "+v.addr.String()+": ")+sValue.Render(v.note+"█"),
+			sDim.Render("Enter to reserve, Esc to cancel"))
+	} else {
+		var actions []string
+		if _, ok := pool.Next(4); ok {
+			actions = append(actions, sKey.Render("R")+" Reserve next IPv4")
+		}
+		if _, ok := pool.Next(6); ok {
+			actions = append(actions, sKey.Render("6")+" Reserve next IPv6")
+		}
+		if len(reserved) > 0 {
+			actions = append(actions, sKey.Render("D")+" Release")
+		}
+		actions = append(actions, sKey.Render("Esc")+" Close")
+		lines = append(lines, strings.Join(actions, "  "))
+	}
+
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
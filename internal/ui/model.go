@@ -1,10 +1,19 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"wireguard-tui/internal/audit"
+	"wireguard-tui/internal/backup"
+	"wireguard-tui/internal/diff"
+	"wireguard-tui/internal/ipam"
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/state"
 	"wireguard-tui/internal/wg"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,37 +22,217 @@ import (
 
 type tickMsg time.Time
 
+// ErrorMsg is sent to the program by what runs beside the UI, such as the
+// web dashboard, to show an error it has no other way to report
+type ErrorMsg struct{ Err error }
+
 type dataMsg struct {
 	interfaces []wg.Interface
 	peers      map[string][]wg.Peer
+	configs    map[string]*wg.Config
+	pools      map[string]*ipam.Pool
+	reserved   map[string][]ipam.Reservation
+	conflicts  map[string]string
+	traffic    map[string][]monitor.Point
+	events     []monitor.Event
+	attached   bool
+	host       int
+	err        error
+}
+
+// Options configures optional behaviour of the model
+type Options struct {
+	// StateDir holds backups, reservations and key rotation snippets.
+	// Defaults to state.Dir().
+	StateDir string
+	// Backups receives a snapshot of every config file before the app
+	// changes it. Defaults to StateDir/backups.
+	Backups *backup.Store
+	// Reservations stores addresses held back from allocation. Defaults
+	// to StateDir/reservations.json.
+	Reservations *ipam.ReservationStore
+	// RotationWindow is how long to wait for a handshake after rotating
+	// an interface key before offering a rollback. Defaults to 2 minutes.
+	RotationWindow time.Duration
+	// SafetyTimeout is the default delay after which an interface taken
+	// down despite a lockout warning comes back up. Defaults to 1 minute.
+	SafetyTimeout time.Duration
+	// Audit is shown in the audit log viewer. The client is expected to
+	// write to it; nil means auditing is off.
+	Audit *audit.Logger
+	// Policy decides which keys are offered. The client is expected to
+	// enforce it; nil allows everything.
+	Policy *policy.Policy
+	// Daemon is a collector running in the background. When it answers,
+	// history and events come from it instead of from polling here.
+	Daemon monitor.Source
+	// Collector polls the client when there is no daemon. Defaults to a
+	// new one; pass one in to share its history.
+	Collector *monitor.Collector
+	// Hosts are the machines to switch between with Tab, the first being
+	// the one client manages. Daemon and Collector then come from each
+	// host, and the state of remote hosts lives under StateDir/hosts.
+	Hosts []Host
+	// Refresh is how often the interfaces are polled. Defaults to 1 second.
+	Refresh time.Duration
+	// Themes are cycled through with F2. Defaults to Themes.
+	Themes []Theme
+	// ThemeDirs are watched for theme files, Themes being reloaded from
+	// them whenever one changes
+	ThemeDirs []string
+	// Theme is the name of the theme to start with; see ThemeIndex
+	Theme string
+	// NoColor keeps to the mono themes, as NO_COLOR asks
+	NoColor bool
+	// Plain lays the screen out as lines of text, without boxes, spinners
+	// or the mascot, for screen readers
+	Plain bool
+	// Columns lists the columns of the interface list by key, in order;
+	// see ColumnKeys. The last one takes the width left over.
+	Columns []string
+	// ColumnWidths overrides the default width of columns by key
+	ColumnWidths map[string]int
+	// Units is UnitsIEC, the default, or UnitsSI
+	Units string
+	// Alerts are the kinds of events that are toasted as alerts
+	Alerts monitor.Alerts
+	// StaleAfter is handed to the collectors the model creates
+	StaleAfter time.Duration
 }
 
 type Model struct {
-	client     wg.Client
-	interfaces []wg.Interface
-	peers      map[string][]wg.Peer
-	cursor     int
-	width      int
-	height     int
-	err        error
-	tick       time.Duration
-	themeIndex int
-	showHelp   bool
-	showFilter bool
-	filterText string
+	client       wg.Client
+	interfaces   []wg.Interface
+	peers        map[string][]wg.Peer
+	configs      map[string]*wg.Config
+	pools        map[string]*ipam.Pool
+	reserved     map[string][]ipam.Reservation
+	reservations *ipam.ReservationStore
+	cursor       int
+	width        int
+	height       int
+	err          error
+	tick         time.Duration
+	themes       []Theme
+	themeIndex   int
+	showHelp     bool
+	showFilter   bool
+	filterText   string
+	edit         *editSession
+	pane         pane
+	logScroll    int
+	cfgView      configView
+	history      *historyView
+	backups      *backup.Store
+	ipam         *ipamView
+	rotation     *rotation
+	tagged       map[string]bool
+	bulk         *bulkView
+	ops          *opManager
+	guard        *lockoutGuard
+	audit        *audit.Logger
+	auditView    *auditView
+	policy       *policy.Policy
+	attached     bool
+	hosts        []*Host
+	hostIndex    int
+	fleet        *fleetView
+	doctor       *doctorView
+	themeEdit    *themeEditor
+	traffic      map[string][]monitor.Point
+	events       []monitor.Event
+	eventsSeen   time.Time
+	// conflicts says why a down interface could not bind its ListenPort
+	conflicts map[string]string
+
+	stateDir      string
+	rotateWindow  time.Duration
+	safetyTimeout time.Duration
+	themeDirs     []string
+	themeStamp    string
+	noColor       bool
+	plain         bool
+	columns       []column
+	units         string
+	alerts        monitor.Alerts
 }
 
-func NewModel(client wg.Client) Model {
-	return Model{
-		client:     client,
-		tick:       time.Second,
-		themeIndex: 0,
-		peers:      make(map[string][]wg.Peer),
+func NewModel(client wg.Client, opts Options) Model {
+	if opts.StateDir == "" {
+		opts.StateDir = state.Dir()
+	}
+	if opts.Backups == nil {
+		opts.Backups = backup.NewStore(filepath.Join(opts.StateDir, "backups"))
+	}
+	if opts.Reservations == nil {
+		opts.Reservations = ipam.NewReservationStore(filepath.Join(opts.StateDir, "reservations.json"))
+	}
+	if opts.RotationWindow <= 0 {
+		opts.RotationWindow = 2 * time.Minute
+	}
+	if opts.SafetyTimeout <= 0 {
+		opts.SafetyTimeout = time.Minute
 	}
+	if opts.Refresh <= 0 {
+		opts.Refresh = time.Second
+	}
+	if len(opts.Themes) == 0 {
+		opts.Themes = Themes
+	}
+	if opts.NoColor {
+		opts.Themes = monoThemes(opts.Themes)
+	}
+	themeIndex, _ := ThemeIndex(opts.Themes, opts.Theme)
+	if len(opts.Hosts) == 0 {
+		opts.Hosts = []Host{{Client: client, Daemon: opts.Daemon, Collector: opts.Collector}}
+	}
+	var hosts []*Host
+	for _, h := range opts.Hosts {
+		h.stateDir, h.backups, h.reservations = opts.StateDir, opts.Backups, opts.Reservations
+		// Interface names repeat across gateways, so each remote host
+		// keeps its backups and reservations apart
+		if !h.local() {
+			h.stateDir = filepath.Join(opts.StateDir, "hosts", h.Name)
+			h.backups = backup.NewStore(filepath.Join(h.stateDir, "backups"))
+			h.reservations = ipam.NewReservationStore(filepath.Join(h.stateDir, "reservations.json"))
+		}
+		h.cache = newHostCache()
+		if h.Collector == nil {
+			h.Collector = monitor.NewCollector(h.Client)
+			if opts.StaleAfter > 0 {
+				h.Collector.StaleAfter = opts.StaleAfter
+			}
+		}
+		hosts = append(hosts, &h)
+	}
+	m := Model{
+		tick:          opts.Refresh,
+		themes:        opts.Themes,
+		themeIndex:    themeIndex,
+		themeDirs:     opts.ThemeDirs,
+		themeStamp:    themeStamp(opts.ThemeDirs),
+		noColor:       opts.NoColor,
+		plain:         opts.Plain,
+		peers:         make(map[string][]wg.Peer),
+		tagged:        make(map[string]bool),
+		ops:           newOpManager(),
+		rotateWindow:  opts.RotationWindow,
+		safetyTimeout: opts.SafetyTimeout,
+		audit:         opts.Audit,
+		policy:        opts.Policy,
+		hosts:         hosts,
+		eventsSeen:    time.Now(),
+		columns:       newColumns(opts.Columns, opts.ColumnWidths),
+		units:         opts.Units,
+		alerts:        opts.Alerts,
+	}
+	m.ops.still = opts.Plain
+	m.useHost(0)
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(m.refreshData, m.tickCmd())
+	return tea.Batch(m.reload(), m.tickCmd())
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -62,7 +251,49 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.filterText += msg.String()
 				}
 			}
-			return m, nil
+			cmd := m.syncConfigView()
+			return m, cmd
+		}
+
+		// The guard can come up over the editor, for Save & restart
+		if m.guard != nil {
+			return m.updateGuard(msg)
+		}
+
+		if m.edit != nil {
+			return m.updateEdit(msg)
+		}
+
+		if m.themeEdit != nil {
+			return m.updateThemeEditor(msg)
+		}
+
+		if m.history != nil {
+			return m.updateHistory(msg)
+		}
+
+		if m.ipam != nil {
+			return m.updateIPAM(msg)
+		}
+
+		if m.rotation != nil {
+			return m.updateRotation(msg)
+		}
+
+		if m.bulk != nil {
+			return m.updateBulk(msg)
+		}
+
+		if m.auditView != nil {
+			return m.updateAudit(msg)
+		}
+
+		if m.fleet != nil {
+			return m.updateFleet(msg)
+		}
+
+		if m.doctor != nil {
+			return m.updateDoctor(msg)
 		}
 
 		if m.showHelp {
@@ -78,43 +309,319 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "f1", "?":
 			m.showHelp = !m.showHelp
 		case "f2":
-			m.themeIndex = (m.themeIndex + 1) % len(Themes)
+			m.themeIndex = (m.themeIndex + 1) % len(m.themes)
+		case "shift+f2", "C":
+			if t := m.themes[m.themeIndex]; t.Mono {
+				m.ops.toast(t.Name+" has no colors to edit", true)
+			} else {
+				m.themeEdit = newThemeEditor(t)
+			}
+		case "esc":
+			filtered := m.getFilteredInterfaces()
+			if m.cursor < len(filtered) {
+				m.ops.cancel(filtered[m.cursor].Name)
+			}
+		case "tab", "shift+tab":
+			step := 1
+			if msg.String() == "shift+tab" {
+				step = -1
+			}
+			if m.switchHost((m.hostIndex + step + len(m.hosts)) % len(m.hosts)) {
+				return m, m.refresh()
+			}
 		case "f5", "r":
 			m.err = nil
-			return m, m.refreshData
+			return m, m.refresh()
+		case "f4", "e":
+			filtered := m.getFilteredInterfaces()
+			if m.cursor < len(filtered) && m.allowed(policy.Edit, filtered[m.cursor].Name) {
+				return m, m.editCmd(filtered[m.cursor])
+			}
+		case "f7", "h":
+			filtered := m.getFilteredInterfaces()
+			if m.cursor < len(filtered) {
+				return m, m.historyCmd(filtered[m.cursor])
+			}
+		case "f8", "i":
+			filtered := m.getFilteredInterfaces()
+			if m.cursor < len(filtered) {
+				m.ipam = &ipamView{iface: filtered[m.cursor].Name}
+			}
+		case "ctrl+r":
+			filtered := m.getFilteredInterfaces()
+			if m.cursor < len(filtered) && m.allowed(policy.Rotate, filtered[m.cursor].Name) {
+				m.rotation = &rotation{iface: filtered[m.cursor]}
+			}
+		case "t", "insert":
+			m.tagToggle()
+			cmd := m.syncConfigView()
+			return m, cmd
+		case "T":
+			m.tagFiltered()
+		case "U":
+			for name := range m.tagged {
+				delete(m.tagged, name)
+			}
+		case "f9", "b":
+			names := m.taggedNames()
+			if len(names) == 0 {
+				// Like htop, act on the selection when nothing is tagged
+				filtered := m.getFilteredInterfaces()
+				if m.cursor >= len(filtered) {
+					return m, nil
+				}
+				names = []string{filtered[m.cursor].Name}
+			}
+			m.bulk = &bulkView{names: names}
+		case "a":
+			m.auditView = &auditView{}
+			if h := m.hosts[m.hostIndex]; !h.local() {
+				m.auditView.host = h.Name
+			}
+			filtered := m.getFilteredInterfaces()
+			if m.cursor < len(filtered) {
+				m.auditView.iface = filtered[m.cursor].Name
+			}
+			if m.audit != nil {
+				return m, m.loadAuditCmd()
+			}
+		case "F":
+			m.fleet = &fleetView{}
+			return m, m.pollFleetCmd()
+		case "D":
+			m.doctor = &doctorView{}
+			return m, m.doctorCmd()
 		case "f6", "/":
 			m.showFilter = true
 			m.filterText = ""
+		case "f3", "v":
+			if m.pane == paneConfig {
+				m.pane = paneDetails
+				m.cfgView = configView{}
+				return m, nil
+			}
+			m.pane = paneConfig
+			cmd := m.syncConfigView()
+			return m, cmd
+		case "o":
+			m.cfgView = configView{}
+			m.logScroll = 0
+			if m.pane == paneLog {
+				m.pane = paneDetails
+			} else {
+				m.pane = paneLog
+			}
+		case "s":
+			if m.pane == paneConfig && (m.cfgView.reveal || m.allowed(policy.Secrets, m.cfgView.name)) {
+				m.cfgView.reveal = !m.cfgView.reveal
+			}
+		case "pgup":
+			if m.pane == paneLog {
+				m.logScroll += 5
+			}
+			if m.pane == paneConfig && m.cfgView.scroll > 0 {
+				m.cfgView.scroll -= 5
+				if m.cfgView.scroll < 0 {
+					m.cfgView.scroll = 0
+				}
+			}
+		case "pgdown":
+			if m.pane == paneLog {
+				m.logScroll -= 5
+				if m.logScroll < 0 {
+					m.logScroll = 0
+				}
+			}
+			if m.pane == paneConfig {
+				m.cfgView.scroll += 5
+				if last := strings.Count(string(m.cfgView.data), "\n"); m.cfgView.scroll > last {
+					m.cfgView.scroll = last
+				}
+			}
 		case "up", "k":
 			if m.cursor > 0 {
 				m.cursor--
 			}
+			cmd := m.syncConfigView()
+			return m, cmd
 		case "down", "j":
 			if m.cursor < m.getFilteredCount()-1 {
 				m.cursor++
 			}
+			cmd := m.syncConfigView()
+			return m, cmd
 		case " ":
 			filtered := m.getFilteredInterfaces()
 			if m.cursor < len(filtered) {
 				iface := filtered[m.cursor]
+				// Bringing up an interface that may be running already
+				// would only fail, and taking it down may not be wanted
+				if iface.Status == wg.InterfaceUnknown {
+					m.ops.toast(iface.Name+": state unknown while wg fails, not toggling", true)
+					return m, nil
+				}
 				newState := iface.Status == wg.InterfaceDown
-				return m, m.toggleCmd(iface.Name, newState)
+				action := policy.Down
+				if newState {
+					action = policy.Up
+				}
+				if !m.allowed(action, iface.Name) {
+					return m, nil
+				}
+				// Follow the wg-quick output while it runs
+				if m.pane == paneDetails {
+					m.pane = paneLog
+					m.logScroll = 0
+				}
+				if !newState {
+					return m, m.downCmd(iface.Name)
+				}
+				return m, m.submit(m.toggleOp(iface.Name, newState))
 			}
 		}
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 	case tickMsg:
-		return m, tea.Batch(m.refreshData, m.tickCmd())
+		m.ops.pruneToasts()
+		m.checkSafetyTimer()
+		m.reloadThemes()
+		var fleet tea.Cmd
+		if m.fleet != nil {
+			fleet = m.fleetTick()
+		}
+		// Skip the tick rather than pile refreshes onto a slow wg
+		if m.ops.refreshing {
+			return m, tea.Batch(fleet, m.tickCmd())
+		}
+		return m, tea.Batch(m.reload(), fleet, m.tickCmd())
+	case spinMsg:
+		return m.updateSpin()
+	case ErrorMsg:
+		m.ops.toast(msg.Err.Error(), true)
+	case opDoneMsg:
+		return m.updateOpDone(msg)
+	case fleetMsg:
+		return m.updateFleetPolled(msg)
+	case doctorMsg:
+		return m.updateDoctorDone(msg)
+	case auditLoadedMsg:
+		if v := m.auditView; v != nil {
+			v.records, v.err = msg.records, msg.err
+			if v.cursor >= len(v.visible()) {
+				v.cursor = 0
+			}
+		}
+	case lockoutCheckMsg:
+		return m.updateLockoutCheck(msg)
+	case safetyArmedMsg:
+		return m.updateSafetyArmed(msg)
+	case safetyDisarmedMsg:
+		return m.updateSafetyDisarmed(msg)
+	case editReadyMsg:
+		return m, runEditor(msg.session)
+	case editorDoneMsg:
+		if msg.err == nil {
+			msg.err = msg.session.review()
+		}
+		if msg.err != nil {
+			msg.session.close()
+			m.edit = nil
+			m.err = fmt.Errorf("editor: %v", msg.err)
+			return m, nil
+		}
+		m.edit = msg.session
+	case editAppliedMsg:
+		if msg.err == errCancelled {
+			// Nothing was changed, so the edit can still be applied
+			msg.session.busy = false
+			m.ops.toast(msg.session.iface.Name+": cancelled, the file on disk is unchanged", true)
+			return m, nil
+		}
+		msg.session.close()
+		m.edit = nil
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		// Force the viewer to pick up the new file
+		m.cfgView.name = ""
+		cmd := m.syncConfigView()
+		return m, tea.Batch(m.refresh(), cmd)
+	case historyLoadedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.history = msg.view
+		return m, m.history.selectVersion(m)
+	case historyVersionMsg:
+		h := m.history
+		if h == nil || h.cursor >= len(h.versions) || h.versions[h.cursor].ID != msg.id {
+			return m, nil
+		}
+		h.loadedID = msg.id
+		h.err = msg.err
+		if msg.err == nil {
+			h.diff = diff.Context(diff.Text(string(h.current), string(msg.data)), 2)
+		}
+	case historyRestoredMsg:
+		m.history = nil
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		m.cfgView.name = ""
+		cmd := m.syncConfigView()
+		return m, tea.Batch(m.refresh(), cmd)
+	case bulkStepMsg:
+		return m.updateBulkStep(msg)
+	case rotationReadyMsg, rotationAppliedMsg, rotationRolledBackMsg:
+		return m.updateRotationResult(msg)
+	case reservationMsg:
+		if msg.err != nil {
+			m.err = msg.err
+		}
+		return m, m.refresh()
+	case configLoadedMsg:
+		if msg.name == m.cfgView.name {
+			m.cfgView.data = msg.data
+			m.cfgView.err = msg.err
+		}
 	case dataMsg:
+		m.ops.refreshing = false
+		var next tea.Cmd
+		if m.ops.stale {
+			m.ops.stale = false
+			next = m.reload()
+		}
+		// A refresh started before a host switch
+		if msg.host != m.hostIndex {
+			return m, next
+		}
+		if msg.err != nil {
+			m.err = msg.err
+			if msg.interfaces == nil {
+				return m, next
+			}
+		}
 		m.interfaces = msg.interfaces
 		m.peers = msg.peers
+		m.configs = msg.configs
+		m.pools = msg.pools
+		m.reserved = msg.reserved
+		m.conflicts = msg.conflicts
+		m.traffic = msg.traffic
+		m.events = msg.events
+		m.attached = msg.attached
+		m.announceEvents(msg.events)
+		m.checkRotation()
 		if m.cursor >= len(m.interfaces) {
 			m.cursor = len(m.interfaces) - 1
 		}
 		if m.cursor < 0 {
 			m.cursor = 0
 		}
+		cmd := m.syncConfigView()
+		return m, tea.Batch(cmd, next)
 	case error:
 		m.err = msg
 	}
@@ -122,7 +629,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) View() string {
-	theme := Themes[m.themeIndex]
+	theme := m.themes[m.themeIndex]
 	width := m.width
 	if width == 0 {
 		width = 80
@@ -131,37 +638,55 @@ func (m Model) View() string {
 	if height == 0 {
 		height = 24
 	}
+	// The theme editor sits beside the dashboard when there is room,
+	// everything being drawn in the theme it edits
+	besideEditor := false
+	if m.themeEdit != nil {
+		theme = m.themeEdit.theme
+		if besideEditor = width >= 2*themeEditorWidth; besideEditor {
+			width -= themeEditorWidth
+		}
+	}
 
 	// Styles
-	sHeader := lipgloss.NewStyle().Foreground(theme.HeaderFg).Background(theme.HeaderBg).Bold(true)
-	sColHdr := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Background(theme.ColumnHeaderBg).Bold(true)
-	sSel := lipgloss.NewStyle().Foreground(theme.SelectedFg).Background(theme.SelectedBg)
+	sHeader := theme.fill(theme.HeaderFg, theme.HeaderBg).Bold(true)
+	sColHdr := theme.fill(theme.ColumnHeaderFg, theme.ColumnHeaderBg).Bold(true)
+	sSel := theme.fill(theme.SelectedFg, theme.SelectedBg)
 	sNorm := lipgloss.NewStyle().Foreground(theme.NormalFg)
-	sKey := lipgloss.NewStyle().Foreground(theme.KeyFg).Background(theme.KeyBg).Bold(true).Padding(0, 1)
-	sDesc := lipgloss.NewStyle().Foreground(theme.DescFg).Background(theme.DescBg).Padding(0, 0)
-	sError := lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sDesc := theme.fill(theme.DescFg, theme.DescBg).Padding(0, 0)
+	sError := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
 	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
 
 	// Item Styles for Robust Alignment
-	wName := 12
-	wStatus := 8
-	wPort := 7
-	wPeers := 10
-	wTransfer := 22
-	wActive := width - wName - wStatus - wPort - wPeers - wTransfer - 2
-	if wActive < 8 {
-		wActive = 8
-	}
-
-	stName := lipgloss.NewStyle().Width(wName)
-	stStatus := lipgloss.NewStyle().Width(wStatus).PaddingRight(1)
-	stPort := lipgloss.NewStyle().Width(wPort)
-	stPeers := lipgloss.NewStyle().Width(wPeers)
-	stTrans := lipgloss.NewStyle().Width(wTransfer)
-	stActive := lipgloss.NewStyle().Width(wActive)
+	widths := m.columnWidths(width)
+	wName, wPort := widths["name"], widths["port"]
+	colStyle := func(key string) lipgloss.Style {
+		if key == "status" {
+			return lipgloss.NewStyle().Width(widths[key]).PaddingRight(1)
+		}
+		return lipgloss.NewStyle().Width(widths[key])
+	}
+	// joinColumns lays out the cells of a row in the configured order
+	joinColumns := func(cells map[string]string) string {
+		var parts []string
+		for _, c := range m.columns {
+			parts = append(parts, colStyle(c.key).Render(cells[c.key]))
+		}
+		return lipgloss.JoinHorizontal(lipgloss.Top, parts...)
+	}
 
 	// 1. Header
 	headerText := fmt.Sprintf(" WireGuard TUI (%s) ", theme.Name)
+	if len(m.hosts) > 1 || !m.hosts[m.hostIndex].local() {
+		headerText += "@" + m.hostLabel() + " "
+	}
+	if label := m.policy.Label(); label != "" {
+		headerText += "[" + label + "] "
+	}
+	if m.attached {
+		headerText += "[daemon] "
+	}
 	clock := time.Now().Format("15:04:05")
 	padLen := width - lipgloss.Width(headerText) - len(clock)
 	if padLen < 0 {
@@ -176,18 +701,19 @@ func (m Model) View() string {
 		if lipgloss.Width(errorLine) < width {
 			errorLine += strings.Repeat(" ", width-lipgloss.Width(errorLine))
 		}
-		errorLine = sHeader.Background(lipgloss.Color("0")).Render(errorLine) + "\n"
+		errorLine = theme.fill(theme.HeaderFg, lipgloss.Color("0")).Bold(true).Render(errorLine) + "\n"
+		if hint := errorHint(m.err); hint != "" {
+			hint = truncate(hint, width)
+			errorLine += sDesc.Render(hint+strings.Repeat(" ", max(width-lipgloss.Width(hint), 0))) + "\n"
+		}
 	}
 
 	// 2. Column Headers
-	colHeader := sColHdr.Render(lipgloss.JoinHorizontal(lipgloss.Top,
-		stName.Render("Interface"),
-		stStatus.Render("Status"),
-		stPort.Render("Port"),
-		stPeers.Render("Peers"),
-		stTrans.Render("Transfer (Total)"),
-		stActive.Render("Active (Latest)"),
-	))
+	titles := make(map[string]string)
+	for _, c := range m.columns {
+		titles[c.key] = c.title
+	}
+	colHeader := sColHdr.Render(joinColumns(titles))
 	if wh := lipgloss.Width(colHeader); wh < width {
 		colHeader += sColHdr.Render(strings.Repeat(" ", width-wh))
 	}
@@ -201,6 +727,12 @@ func (m Model) View() string {
 	if m.err != nil {
 		listHeight--
 	}
+	if errorHint(m.err) != "" {
+		listHeight--
+	}
+	if len(m.ops.toasts) > 0 {
+		listHeight--
+	}
 	if listHeight < 3 {
 		listHeight = 3
 	}
@@ -215,15 +747,30 @@ func (m Model) View() string {
 		endRow = len(filtered)
 	}
 
-	onSty := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
-	offSty := lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+	onSty := lipgloss.NewStyle().Foreground(theme.GoodFg).Bold(true)
+	offSty := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+	unknownSty := lipgloss.NewStyle().Foreground(theme.WarnFg).Bold(true)
+	onLabel, offLabel, unknownLabel := "[ON]", "[OFF]", "[?]"
+	if theme.Mono {
+		// Without colors the state has to be seen in the shape and weight
+		offSty, unknownSty = lipgloss.NewStyle(), lipgloss.NewStyle()
+		onLabel, offLabel, unknownLabel = "● ON", "○ OFF", "? ---"
+	}
+	sTag := lipgloss.NewStyle().Foreground(theme.KeyBg).Bold(true)
+	sPending := lipgloss.NewStyle().Foreground(theme.HeaderBg).Bold(true)
 
 	var bodyRows []string
 	for i := startRow; i < endRow; i++ {
 		iface := filtered[i]
-		statusStr := offSty.Render("[OFF]")
-		if iface.Status == wg.InterfaceUp {
-			statusStr = onSty.Render("[ON]")
+		statusStr := offSty.Render(offLabel)
+		switch iface.Status {
+		case wg.InterfaceUp:
+			statusStr = onSty.Render(onLabel)
+		case wg.InterfaceUnknown:
+			statusStr = unknownSty.Render(unknownLabel)
+		}
+		if label := m.ops.pendingLabel(iface.Name); label != "" {
+			statusStr = sPending.Render(label)
 		}
 
 		var totalRx, totalTx int64
@@ -240,7 +787,7 @@ func (m Model) View() string {
 		activeStr := "-"
 		if iface.Status == wg.InterfaceUp {
 			if totalRx > 0 || totalTx > 0 {
-				transferStr = fmt.Sprintf("Rx:%s Tx:%s", formatBytes(totalRx), formatBytes(totalTx))
+				transferStr = fmt.Sprintf("Rx:%s Tx:%s", m.formatBytes(totalRx), m.formatBytes(totalTx))
 			}
 			if !latestHS.IsZero() {
 				activeStr = fmtDur(time.Since(latestHS))
@@ -254,18 +801,28 @@ func (m Model) View() string {
 		}
 
 		portStr := "-"
-		if iface.ListenPort > 0 {
-			portStr = fmt.Sprintf("%d", iface.ListenPort)
+		if port := wg.ListenPort(iface, m.configs[iface.Name]); port > 0 {
+			portStr = fmt.Sprintf("%d", port)
+		}
+		portStr = truncate(portStr, wPort-1)
+		if m.conflicts[iface.Name] != "" {
+			// Warn before Space runs into "Address already in use"
+			portStr = sError.Render(truncate(portStr, wPort-2) + "!")
 		}
 
-		row := lipgloss.JoinHorizontal(lipgloss.Top,
-			stName.Render(truncate(iface.Name, wName-1)),
-			stStatus.Render(statusStr),
-			stPort.Render(truncate(portStr, wPort-1)),
-			stPeers.Render(truncate(peersStr, wPeers-1)),
-			stTrans.Render(truncate(transferStr, wTransfer-1)),
-			stActive.Render(truncate(activeStr, wActive-1)),
-		)
+		nameStr := truncate(iface.Name, wName-1)
+		if m.tagged[iface.Name] {
+			nameStr = sTag.Render("*" + truncate(iface.Name, wName-2))
+		}
+
+		row := joinColumns(map[string]string{
+			"name":     nameStr,
+			"status":   statusStr,
+			"port":     portStr,
+			"peers":    truncate(peersStr, widths["peers"]-1),
+			"transfer": truncate(transferStr, widths["transfer"]-1),
+			"active":   truncate(activeStr, widths["active"]-1),
+		})
 
 		if i == m.cursor {
 			rowWidth := lipgloss.Width(row)
@@ -285,28 +842,64 @@ func (m Model) View() string {
 	// We pass the filtered interface if selected
 	details := ""
 	if len(filtered) > 0 && m.cursor < len(filtered) {
-		details = m.renderDetailsPanelFor(filtered[m.cursor], width, detailsHeight, theme)
+		switch m.pane {
+		case paneConfig:
+			details = m.renderConfigPanel(filtered[m.cursor], width, detailsHeight, theme)
+		case paneLog:
+			details = m.renderLogPanel(filtered[m.cursor], width, detailsHeight, theme)
+		default:
+			details = m.renderDetailsPanelFor(filtered[m.cursor], width, detailsHeight, theme)
+		}
 	} else {
 		details = m.renderDetailsPanel(width, detailsHeight, theme)
 	}
 
 	mainView := header + "\n" + errorLine + colHeader + "\n" + strings.Join(bodyRows, "\n") + "\n" + details
 
+	// Only the newest toast is shown; older ones expire behind it
+	if n := len(m.ops.toasts); n > 0 {
+		t := m.ops.toasts[n-1]
+		sToast := onSty
+		if t.failed {
+			sToast = offSty.Reverse(theme.Mono)
+		}
+		line := truncate(" "+t.text, width)
+		mainView += "\n" + sToast.Render(line+strings.Repeat(" ", width-lipgloss.Width(line)))
+	}
+
 	// 5. Footer / Filter Bar
 	footerView := ""
 	if m.showFilter {
-		fBar := lipgloss.NewStyle().Background(lipgloss.Color("4")).Foreground(lipgloss.Color("0")).Bold(true)
+		fBar := theme.fill(lipgloss.Color("0"), lipgloss.Color("4")).Bold(true)
 		prompt := " Filter: "
 		footerView = fBar.Render(prompt + m.filterText + strings.Repeat(" ", width-lipgloss.Width(prompt+m.filterText)))
 	} else {
+		bulkLabel := "Bulk"
+		if n := len(m.taggedNames()); n > 0 {
+			bulkLabel = fmt.Sprintf("Bulk(%d)", n)
+		}
+		// Keys for actions the policy rules out everywhere are not offered
+		canEdit := m.policy.Permits(policy.Edit)
+		canToggle := m.canToggle()
 		footerItems := []string{
 			sKey.Render("F1") + sDesc.Render("Help"),
 			sKey.Render("F2") + sDesc.Render("Theme"),
-			sKey.Render("F5") + sDesc.Render("Refresh"),
-			sKey.Render("F6") + sDesc.Render("Filter"),
-			sKey.Render("Space") + sDesc.Render("Toggle"),
-			sKey.Render("F10") + sDesc.Render("Quit"),
+			sKey.Render("F3") + sDesc.Render("Config"),
+		}
+		if canEdit {
+			footerItems = append(footerItems, sKey.Render("F4")+sDesc.Render("Edit"))
 		}
+		footerItems = append(footerItems,
+			sKey.Render("F5")+sDesc.Render("Refresh"),
+			sKey.Render("F6")+sDesc.Render("Filter"),
+			sKey.Render("F7")+sDesc.Render("History"),
+			sKey.Render("F8")+sDesc.Render("IPs"),
+			sKey.Render("F9")+sDesc.Render(bulkLabel),
+		)
+		if canToggle {
+			footerItems = append(footerItems, sKey.Render("Space")+sDesc.Render("Toggle"))
+		}
+		footerItems = append(footerItems, sKey.Render("F10")+sDesc.Render("Quit"))
 		fc := strings.Join(footerItems, " ")
 		vl := lipgloss.Width(fc)
 		if vl > width {
@@ -321,11 +914,18 @@ func (m Model) View() string {
 	}
 
 	s := mainView + "\n" + footerView
+	if m.plain {
+		s = m.renderPlain(width, height, theme)
+	}
 
 	// 6. Help Overlay
 	if m.showHelp {
+		viewHelp := " View config"
+		if m.policy.Permits(policy.Secrets) {
+			viewHelp += " (S reveals secrets)"
+		}
 		helpBox := lipgloss.NewStyle().
-			Border(lipgloss.DoubleBorder()).
+			Border(m.border(lipgloss.DoubleBorder())).
 			BorderForeground(theme.KeyBg).
 			Padding(1, 2).
 			Background(theme.ColumnHeaderBg).
@@ -333,9 +933,23 @@ func (m Model) View() string {
 				lipgloss.JoinVertical(lipgloss.Left,
 					sKey.Render("F1 / ?")+" Show this help",
 					sKey.Render("F2")+" Cycle color themes",
+					sKey.Render("Shift-F2 / C")+" Edit the colors of the theme",
+					sKey.Render("Tab / Shift-Tab")+" Switch host",
+					sKey.Render("Shift-F")+" Fleet overview of all hosts",
+					sKey.Render("Shift-D")+" Doctor: check this host is ready for WireGuard",
+					sKey.Render("F3 / V")+viewHelp,
+					sKey.Render("F4 / E")+" Edit config in $EDITOR",
 					sKey.Render("F5 / R")+" Refresh interface status",
 					sKey.Render("F6 / /")+" Search / Filter interfaces",
+					sKey.Render("F7 / H")+" Config backups and restore",
+					sKey.Render("F8 / I")+" Address allocation and reservations",
+					sKey.Render("O")+" wg-quick output of the last toggle",
+					sKey.Render("Ctrl-R")+" Rotate interface key pair",
+					sKey.Render("A")+" Audit log of every change",
+					sKey.Render("T / Ins")+" Tag interface (Shift-T: tag filtered, U: untag all)",
+					sKey.Render("F9 / B")+" Bulk up/down/restart/reload/export on tagged",
 					sKey.Render("Space")+" Toggle Interface (UP/DOWN)",
+					sKey.Render("Esc")+" Stop the operation running on the interface",
 					sKey.Render("Arrows / J,K")+" Navigate list",
 					sKey.Render("F10 / Q")+" Quit Application",
 					"",
@@ -347,6 +961,51 @@ func (m Model) View() string {
 		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, helpBox)
 	}
 
+	if m.guard != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderGuardDialog(width, height, theme))
+	}
+
+	if m.edit != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderEditDialog(width, height, theme))
+	}
+
+	if m.history != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderHistoryDialog(width, height, theme))
+	}
+
+	if m.ipam != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderIPAMDialog(width, height, theme))
+	}
+
+	if m.rotation != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderRotationDialog(width, height, theme))
+	}
+
+	if m.bulk != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderBulkDialog(width, height, theme))
+	}
+
+	if m.auditView != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderAuditDialog(width, height, theme))
+	}
+
+	if m.fleet != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderFleetDialog(width, height, theme))
+	}
+
+	if m.doctor != nil {
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, m.renderDoctorDialog(width, height, theme))
+	}
+
+	if m.themeEdit != nil {
+		editor := m.renderThemeEditor(height, theme)
+		if besideEditor {
+			// The footer may not fit the narrower dashboard
+			return lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().MaxWidth(width).Render(s), editor)
+		}
+		return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, editor)
+	}
+
 	return s
 }
 
@@ -367,9 +1026,18 @@ func (m Model) getFilteredCount() int {
 	return len(m.getFilteredInterfaces())
 }
 
+// canToggle says whether Space is offered: the policy allows it and the
+// state of the selected interface is known
+func (m Model) canToggle() bool {
+	if filtered := m.getFilteredInterfaces(); m.cursor < len(filtered) && filtered[m.cursor].Status == wg.InterfaceUnknown {
+		return false
+	}
+	return m.policy.Permits(policy.Up) || m.policy.Permits(policy.Down)
+}
+
 func (m Model) renderDetailsPanelFor(iface wg.Interface, width, height int, theme Theme) string {
 	sPanel := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
+		Border(m.border(lipgloss.RoundedBorder())).
 		BorderForeground(theme.ColumnHeaderFg).
 		Padding(0, 1).
 		Width(width - 2).
@@ -382,15 +1050,19 @@ func (m Model) renderDetailsPanelFor(iface wg.Interface, width, height int, them
 	sAccent := lipgloss.NewStyle().Foreground(theme.HeaderBg).Bold(true)
 
 	var b strings.Builder
-	status := "UP"
-	if iface.Status == wg.InterfaceDown {
-		status = "DOWN"
-	}
+	status := iface.Status.String()
 	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
 		sLabel.Render("Interface: "), sAccent.Render(iface.Name),
 		strings.Repeat(" ", 4),
 		sLabel.Render("Status: "), sValue.Render(status),
 	) + "\n")
+	if label := m.ops.pendingLabel(iface.Name); label != "" {
+		pending := label
+		if n := len(m.ops.queued[iface.Name]); n > 0 {
+			pending += fmt.Sprintf(" (%d queued)", n)
+		}
+		b.WriteString(sLabel.Render("Pending: ") + sAccent.Render(pending) + sDim.Render("  Esc stops it") + "\n")
+	}
 
 	pk := iface.PublicKey
 	if pk == "" {
@@ -399,16 +1071,38 @@ func (m Model) renderDetailsPanelFor(iface wg.Interface, width, height int, them
 	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
 		sLabel.Render("Public Key: "), sValue.Render(truncate(pk, 16)),
 		strings.Repeat(" ", 2),
-		sLabel.Render("Port: "), sValue.Render(fmt.Sprintf("%d", iface.ListenPort)),
+		sLabel.Render("Port: "), sValue.Render(fmt.Sprintf("%d", wg.ListenPort(iface, m.configs[iface.Name]))),
 		strings.Repeat(" ", 2),
 		sLabel.Render("FwMark: "), sValue.Render(fmt.Sprintf("%d", iface.FirewallMark)),
 	) + "\n")
+	if conflict := m.conflicts[iface.Name]; conflict != "" {
+		sConflict := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+		b.WriteString(sLabel.Render("Conflict: ") + sConflict.Render(truncate(conflict, width-16)) + "\n")
+	}
+
+	if summary := m.ipamSummary(iface.Name); summary != "" {
+		b.WriteString(sLabel.Render("Addresses: ") + sValue.Render(truncate(summary, width-17)) + "\n")
+	}
+	if line := m.trafficLine(iface.Name, width-15); line != "" {
+		b.WriteString(sLabel.Render("Traffic: ") + sAccent.Render(line) + "\n")
+	}
+	if e, ok := m.lastEvent(iface.Name); ok {
+		sEvent := sValue
+		if m.alerts.Match(e) {
+			sEvent = lipgloss.NewStyle().Foreground(theme.BadFg)
+		}
+		text := e.Time.Format("15:04:05") + " " + e.String()
+		b.WriteString(sLabel.Render("Last Event: ") + sEvent.Render(truncate(text, width-18)) + "\n")
+	}
 
 	peers := m.peers[iface.Name]
 	if len(peers) == 0 {
-		if iface.Status == wg.InterfaceDown {
+		switch iface.Status {
+		case wg.InterfaceDown:
 			b.WriteString(sDim.Render("\nInterface is DOWN — no peer data"))
-		} else {
+		case wg.InterfaceUnknown:
+			b.WriteString(sDim.Render("\nState unknown, wg failed — no peer data"))
+		default:
 			b.WriteString(sDim.Render("\nNo peers configured"))
 		}
 	} else {
@@ -422,7 +1116,7 @@ func (m Model) renderDetailsPanelFor(iface wg.Interface, width, height int, them
 		b.WriteString(sDim.Render(truncate(hdr, iw)) + "\n")
 
 		for _, p := range peers {
-			tx := fmt.Sprintf("Rx:%s Tx:%s", formatBytes(p.TransferRx), formatBytes(p.TransferTx))
+			tx := fmt.Sprintf("Rx:%s Tx:%s", m.formatBytes(p.TransferRx), m.formatBytes(p.TransferTx))
 			hs := "Never"
 			if !p.LatestHandshake.IsZero() {
 				hs = fmtDur(time.Since(p.LatestHandshake))
@@ -451,37 +1145,76 @@ func (m Model) renderDetailsPanel(width, height int, theme Theme) string {
 	if len(m.interfaces) > 0 && m.cursor < len(m.interfaces) {
 		return m.renderDetailsPanelFor(m.interfaces[m.cursor], width, height, theme)
 	}
-	sPanel := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(theme.ColumnHeaderFg).Padding(0, 1).Width(width - 2).Height(height - 2)
+	sPanel := lipgloss.NewStyle().Border(m.border(lipgloss.RoundedBorder())).BorderForeground(theme.ColumnHeaderFg).Padding(0, 1).Width(width - 2).Height(height - 2)
 	return sPanel.Render("No interface selected")
 }
 
-func (m Model) toggleCmd(name string, up bool) tea.Cmd {
-	return func() tea.Msg {
-		err := m.client.ToggleInterface(name, up)
-		if err != nil {
-			return err
+func (m Model) refreshData(fresh bool) tea.Msg {
+	// When wg fails there may still be configs to list
+	snap, attached, err := m.hosts[m.hostIndex].snapshot(context.Background(), fresh, trafficPoints, eventCount)
+	if snap == nil {
+		return dataMsg{host: m.hostIndex, err: err}
+	}
+	ifaces, peers := snap.Interfaces, snap.Peers
+
+	// Configs feed the address pools; interfaces created without
+	// wg-quick simply have none
+	cache := m.hosts[m.hostIndex].cache
+	reserved, reservedStamp := cache.loadReservations(m.reservations)
+	configs := make(map[string]*wg.Config)
+	pools := make(map[string]*ipam.Pool)
+	for _, iface := range ifaces {
+		// Over SSH or through the helper every read is a round trip, so
+		// only the configs that changed are read again, and all of them
+		// when asked to or after a change
+		data, ok := cache.configData(iface)
+		if !ok || fresh {
+			var err error
+			if data, err = m.client.ReadConfig(context.Background(), iface.Name); err != nil {
+				continue
+			}
+		}
+		cfg, pool := cache.config(iface, data, peers[iface.Name], reserved[iface.Name], reservedStamp)
+		if cfg == nil {
+			continue
 		}
-		return m.refreshData()
+		configs[iface.Name] = cfg
+		if pool != nil {
+			pools[iface.Name] = pool
+		}
+	}
+	return dataMsg{
+		interfaces: ifaces, peers: peers, configs: configs, pools: pools, reserved: reserved,
+		conflicts: m.portConflicts(ifaces, configs), traffic: snap.History, events: snap.Events,
+		attached: attached, host: m.hostIndex, err: err,
 	}
 }
 
-func (m Model) refreshData() tea.Msg {
-	ifaces, err := m.client.GetInterfaces()
-	if err != nil {
-		return err
+// portConflicts checks the ListenPorts of down interfaces against every
+// socket on the host, so a clash shows before the interface is brought up
+func (m Model) portConflicts(ifaces []wg.Interface, configs map[string]*wg.Config) map[string]string {
+	pi, ok := wg.As[wg.PortInspector](m.client)
+	if !ok {
+		return nil
 	}
-	peers := make(map[string][]wg.Peer)
+	var ports []int
 	for _, iface := range ifaces {
-		if iface.Status == wg.InterfaceUp {
-			p, _ := m.client.GetPeers(iface.Name)
-			peers[iface.Name] = p
+		if port := wg.ListenPort(iface, configs[iface.Name]); port > 0 && iface.Status == wg.InterfaceDown {
+			ports = append(ports, port)
 		}
 	}
-	return dataMsg{interfaces: ifaces, peers: peers}
+	if len(ports) == 0 {
+		return nil
+	}
+	sockets, err := pi.UDPSockets(context.Background(), ports)
+	if err != nil {
+		return nil
+	}
+	return wg.PortConflicts(ifaces, configs, sockets)
 }
 
 func (m Model) tickCmd() tea.Cmd {
-	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+	return tea.Tick(m.tick, func(t time.Time) tea.Msg { return tickMsg(t) })
 }
 
 func truncate(s string, maxLen int) string {
@@ -497,17 +1230,31 @@ func truncate(s string, maxLen int) string {
 	return s
 }
 
-func formatBytes(bytes int64) string {
-	const unit = 1024
+// errorHint explains an error whose cause is known and suggests a fix
+func errorHint(err error) string {
+	e := wg.Cause(err)
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf(" Hint: %s; %s", e.Explain(), e.Fix())
+}
+
+// formatBytes shows a byte count in powers of 1024 (K, M, …), or of 1000
+// (kB, MB, …) with SI units
+func (m Model) formatBytes(bytes int64) string {
+	unit, prefixes, suffix := int64(1024), "KMGTPE", ""
+	if m.units == UnitsSI {
+		unit, prefixes, suffix = 1000, "kMGTPE", "B"
+	}
 	if bytes < unit {
 		return fmt.Sprintf("%dB", bytes)
 	}
-	div, exp := int64(unit), 0
+	div, exp := unit, 0
 	for n := bytes / unit; n >= unit; n /= unit {
 		div *= unit
 		exp++
 	}
-	return fmt.Sprintf("%.1f%c", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return fmt.Sprintf("%.1f%c%s", float64(bytes)/float64(div), prefixes[exp], suffix)
 }
 
 func fmtDur(d time.Duration) string {
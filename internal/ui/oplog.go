@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"wireguard-tui/internal/wg"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxLogBytes caps the output kept for one operation, in case a hook script
+// goes wild
+const maxLogBytes = 256 << 10
+
+// opLog is the output of the last operation on an interface. The command
+// writes to it from its own goroutine while View reads it.
+type opLog struct {
+	verb    string
+	started time.Time
+
+	mu       sync.Mutex
+	buf      []byte
+	finished time.Time
+	err      error
+}
+
+func (l *opLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if room := maxLogBytes - len(l.buf); room > 0 {
+		if len(p) > room {
+			l.buf = append(l.buf, p[:room]...)
+		} else {
+			l.buf = append(l.buf, p...)
+		}
+	}
+	return len(p), nil
+}
+
+func (l *opLog) finish(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finished = time.Now()
+	l.err = err
+}
+
+func (l *opLog) snapshot() (lines []string, finished time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if text := strings.TrimRight(string(l.buf), "\n"); text != "" {
+		lines = strings.Split(text, "\n")
+	}
+	return lines, l.finished, l.err
+}
+
+func (m Model) renderLogPanel(iface wg.Interface, width, height int, theme Theme) string {
+	sPanel := lipgloss.NewStyle().
+		Border(m.border(lipgloss.RoundedBorder())).
+		BorderForeground(theme.ColumnHeaderFg).
+		Padding(0, 1).
+		Width(width - 2).
+		Height(height - 2)
+	sLabel := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sAccent := lipgloss.NewStyle().Foreground(theme.HeaderBg).Bold(true)
+	sOK := lipgloss.NewStyle().Foreground(theme.GoodFg).Bold(true)
+	sError := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+
+	inner := width - 6
+	visible := height - 3
+	if visible < 1 {
+		visible = 1
+	}
+
+	l := m.ops.logs[iface.Name]
+	if l == nil {
+		title := sLabel.Render("Output of " + iface.Name)
+		return sPanel.Render(title + "\n" + sDim.Render("Nothing has been run on this interface yet. Toggle it with Space."))
+	}
+
+	lines, finished, err := l.snapshot()
+	var status string
+	switch {
+	case finished.IsZero():
+		status = sAccent.Render(m.ops.pendingLabel(iface.Name))
+	case err != nil:
+		status = sError.Render("failed")
+	default:
+		status = sOK.Render(fmt.Sprintf("done in %s", finished.Sub(l.started).Round(100*time.Millisecond)))
+	}
+	title := sLabel.Render(fmt.Sprintf("Output of %s, %s at %s  ", iface.Name, l.verb, l.started.Format("15:04:05"))) +
+		status + sDim.Render("  PgUp/PgDn scroll")
+
+	if err != nil {
+		lines = append(lines, "", "error: "+err.Error())
+	}
+	if len(lines) == 0 {
+		if finished.IsZero() {
+			lines = []string{"Waiting for output…"}
+		} else {
+			lines = []string{"(no output)"}
+		}
+	}
+
+	// logScroll counts lines up from the bottom, so a running command
+	// stays followed until the user scrolls back
+	end := len(lines) - m.logScroll
+	if end < visible {
+		end = visible
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	start := end - visible
+	if start < 0 {
+		start = 0
+	}
+
+	var out []string
+	for _, line := range lines[start:end] {
+		line = truncate(line, inner)
+		switch {
+		case strings.HasPrefix(line, "[#] "):
+			out = append(out, sDim.Render("[#] ")+sValue.Render(line[4:]))
+		case strings.HasPrefix(line, "error: "):
+			out = append(out, sError.Render(line))
+		default:
+			out = append(out, sValue.Render(line))
+		}
+	}
+	return sPanel.Render(title + "\n" + strings.Join(out, "\n"))
+}
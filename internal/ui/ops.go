@@ -0,0 +1,266 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// operation is a state change of one interface that runs in the background
+type operation struct {
+	iface string
+	// verb describes the operation while it runs, e.g. "going up"
+	verb string
+	// done is the toast shown when it succeeds
+	done string
+	run  func(ctx context.Context, out io.Writer) error
+	// notify, if set, reports the outcome to whoever submitted the
+	// operation instead of a toast
+	notify func(error) tea.Msg
+	// cancel stops the operation once it runs
+	cancel context.CancelFunc
+}
+
+type toast struct {
+	text    string
+	failed  bool
+	expires time.Time
+}
+
+// opManager runs at most one operation per interface at a time and queues
+// the rest behind it, so two changes to the same interface never race
+type opManager struct {
+	running  map[string]*operation
+	queued   map[string][]*operation
+	logs     map[string]*opLog
+	toasts   []toast
+	frame    int
+	spinning bool
+	// still leaves out the spinner, for plain mode
+	still bool
+	// refreshing is set while a refresh is in flight, and stale when an
+	// operation finished during it, as its result may predate the change
+	refreshing bool
+	stale      bool
+	// fresh is set when the next refresh has to poll rather than take
+	// what the daemon last saw, as after a change
+	fresh bool
+}
+
+type opDoneMsg struct {
+	op  *operation
+	err error
+}
+
+type spinMsg struct{}
+
+const toastTTL = 4 * time.Second
+
+// errCancelled is the outcome of an operation stopped with Esc
+var errCancelled = errors.New("cancelled")
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+func newOpManager() *opManager {
+	return &opManager{
+		running: make(map[string]*operation),
+		queued:  make(map[string][]*operation),
+		logs:    make(map[string]*opLog),
+	}
+}
+
+func (o *opManager) toast(text string, failed bool) {
+	o.toasts = append(o.toasts, toast{text: text, failed: failed, expires: time.Now().Add(toastTTL)})
+}
+
+func (o *opManager) pruneToasts() {
+	now := time.Now()
+	kept := o.toasts[:0]
+	for _, t := range o.toasts {
+		if t.expires.After(now) {
+			kept = append(kept, t)
+		}
+	}
+	o.toasts = kept
+}
+
+// pendingLabel is the spinner and verb of the operation running on iface,
+// or "" if there is none
+func (o *opManager) pendingLabel(iface string) string {
+	op := o.running[iface]
+	if op == nil {
+		return ""
+	}
+	if o.still {
+		return op.verb + "…"
+	}
+	return spinnerFrames[o.frame%len(spinnerFrames)] + " " + op.verb + "…"
+}
+
+func (m Model) toggleOp(name string, up bool) *operation {
+	if up {
+		return &operation{iface: name, verb: "going up", done: name + " is up", run: func(ctx context.Context, out io.Writer) error {
+			return m.client.ToggleInterface(ctx, name, true, out)
+		}}
+	}
+	return &operation{iface: name, verb: "going down", done: name + " is down", run: func(ctx context.Context, out io.Writer) error {
+		if err := m.snapshotSaveConfig(ctx, name); err != nil {
+			return err
+		}
+		return m.client.ToggleInterface(ctx, name, false, out)
+	}}
+}
+
+func (m Model) restartOp(name string, wasUp bool) *operation {
+	return &operation{iface: name, verb: "restarting", done: name + " restarted", run: func(ctx context.Context, out io.Writer) error {
+		if wasUp {
+			if err := m.snapshotSaveConfig(ctx, name); err != nil {
+				return err
+			}
+			if err := m.client.ToggleInterface(ctx, name, false, out); err != nil {
+				return err
+			}
+		}
+		return m.client.ToggleInterface(ctx, name, true, out)
+	}}
+}
+
+func (m Model) reloadOp(name string) *operation {
+	return &operation{iface: name, verb: "reloading", done: name + " reloaded", run: func(ctx context.Context, _ io.Writer) error {
+		return m.client.SyncConfig(ctx, name)
+	}}
+}
+
+// submit starts op, or queues it behind the operation already running on
+// the same interface. An operation identical to one running or queued is
+// rejected.
+func (m Model) submit(op *operation) tea.Cmd {
+	o := m.ops
+	dup := o.running[op.iface] != nil && o.running[op.iface].verb == op.verb
+	for _, q := range o.queued[op.iface] {
+		if q.verb == op.verb {
+			dup = true
+		}
+	}
+	if dup {
+		err := fmt.Errorf("%s is already %s", op.iface, op.verb)
+		if op.notify != nil {
+			return func() tea.Msg { return op.notify(err) }
+		}
+		o.toast(err.Error(), true)
+		return nil
+	}
+	if o.running[op.iface] != nil {
+		o.queued[op.iface] = append(o.queued[op.iface], op)
+		if op.notify == nil {
+			o.toast(fmt.Sprintf("%s: queued, will start %s after %s", op.iface, op.verb, o.running[op.iface].verb), false)
+		}
+		return nil
+	}
+	return m.startOp(op)
+}
+
+func (m Model) startOp(op *operation) tea.Cmd {
+	o := m.ops
+	o.running[op.iface] = op
+	log := &opLog{verb: op.verb, started: time.Now()}
+	o.logs[op.iface] = log
+	ctx, cancel := context.WithCancel(context.Background())
+	op.cancel = cancel
+	run := func() tea.Msg {
+		err := op.run(ctx, log)
+		// An operation that rolled back after being cancelled says so itself
+		if ctx.Err() != nil && err != nil && !errors.Is(err, errCancelled) {
+			err = errCancelled
+		}
+		cancel()
+		log.finish(err)
+		return opDoneMsg{op: op, err: err}
+	}
+	if o.spinning {
+		return run
+	}
+	o.spinning = true
+	return tea.Batch(run, spinCmd())
+}
+
+// cancel stops the operation running on iface. Whatever is queued behind
+// it still runs.
+func (o *opManager) cancel(iface string) {
+	if op := o.running[iface]; op != nil && op.cancel != nil {
+		op.cancel()
+		o.toast(iface+": cancelling…", false)
+	}
+}
+
+func spinCmd() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg { return spinMsg{} })
+}
+
+func (m Model) updateSpin() (tea.Model, tea.Cmd) {
+	o := m.ops
+	if len(o.running) == 0 || o.still {
+		o.spinning = false
+		return m, nil
+	}
+	o.frame++
+	return m, spinCmd()
+}
+
+func (m Model) updateOpDone(msg opDoneMsg) (tea.Model, tea.Cmd) {
+	o := m.ops
+	op := msg.op
+	delete(o.running, op.iface)
+
+	var cmds []tea.Cmd
+	if op.notify != nil {
+		err := msg.err
+		cmds = append(cmds, func() tea.Msg { return op.notify(err) })
+	} else if errors.Is(msg.err, errCancelled) {
+		o.toast(fmt.Sprintf("%s: cancelled while %s", op.iface, op.verb), true)
+	} else if e := wg.Cause(msg.err); e != nil {
+		// The cause says more than the exit status
+		o.toast(fmt.Sprintf("%s: %s failed, %s; %s", op.iface, op.verb, e.Explain(), e.Fix()), true)
+	} else if msg.err != nil {
+		o.toast(fmt.Sprintf("%s: %s failed: %v", op.iface, op.verb, msg.err), true)
+	} else {
+		o.toast(op.done, false)
+	}
+
+	if q := o.queued[op.iface]; len(q) > 0 {
+		if len(q) == 1 {
+			delete(o.queued, op.iface)
+		} else {
+			o.queued[op.iface] = q[1:]
+		}
+		cmds = append(cmds, m.startOp(q[0]))
+	}
+	cmds = append(cmds, m.refresh())
+	return m, tea.Batch(cmds...)
+}
+
+// refresh reloads interface data. If a refresh is already in flight its
+// result may predate a change just made, so another one follows it.
+func (m Model) refresh() tea.Cmd {
+	m.ops.fresh = true
+	return m.reload()
+}
+
+// reload reloads interface data as the ticks do, from the daemon's last
+// poll when attached to one
+func (m Model) reload() tea.Cmd {
+	if m.ops.refreshing {
+		m.ops.stale = true
+		return nil
+	}
+	m.ops.refreshing = true
+	fresh := m.ops.fresh
+	m.ops.fresh = false
+	return func() tea.Msg { return m.refreshData(fresh) }
+}
@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"wireguard-tui/internal/policy"
+	"wireguard-tui/internal/wg"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// renderPlain is the main screen in plain mode: sentences read top to
+// bottom, with no boxes, columns, clock or mascot to trip up a screen
+// reader. The selected interface is marked with ">" as well as the
+// selection style.
+func (m Model) renderPlain(width, height int, theme Theme) string {
+	sSel := theme.fill(theme.SelectedFg, theme.SelectedBg)
+	sError := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+	// Sentences wrap, as a cut-off one cannot be read to the end
+	wrap := lipgloss.NewStyle().Width(width)
+	line := func(s string) []string { return strings.Split(wrap.Render(s), "\n") }
+
+	title := "WireGuard TUI on " + m.hostLabel()
+	if label := m.policy.Label(); label != "" {
+		title += ", " + label
+	}
+	if m.attached {
+		title += ", data from the daemon"
+	}
+	top := line(title + ".")
+	if m.err != nil {
+		for _, s := range line(fmt.Sprintf("Error: %v", m.err)) {
+			top = append(top, sError.Render(s))
+		}
+		if hint := errorHint(m.err); hint != "" {
+			top = append(top, line(strings.TrimSpace(hint)+".")...)
+		}
+	}
+
+	var bottom []string
+	if n := len(m.ops.toasts); n > 0 {
+		bottom = append(bottom, line("Message: "+m.ops.toasts[n-1].text)...)
+	}
+	if m.showFilter {
+		bottom = append(bottom, line("Filter: "+m.filterText+"_")...)
+	} else {
+		bottom = append(bottom, line("Keys: "+m.plainKeys())...)
+	}
+
+	// The list gets up to half of what is left, the selection the rest
+	filtered := m.getFilteredInterfaces()
+	up := 0
+	for _, iface := range filtered {
+		if iface.Status == wg.InterfaceUp {
+			up++
+		}
+	}
+	rows := max(height-len(top)-len(bottom)-1, 2)
+	listRows := min(len(filtered), max(rows/2-1, 1))
+	start := max(m.cursor-listRows+1, 0)
+	middle := append([]string{""}, line(fmt.Sprintf("%d interfaces, %d up:", len(filtered), up))...)
+	for i := start; i < min(start+listRows, len(filtered)); i++ {
+		if i != m.cursor {
+			middle = append(middle, line("  "+m.plainInterface(filtered[i]))...)
+			continue
+		}
+		for _, s := range line("> " + m.plainInterface(filtered[i])) {
+			middle = append(middle, sSel.Render(s))
+		}
+	}
+	if len(filtered) > 0 && m.cursor < len(filtered) {
+		middle = append(middle, "")
+		switch m.pane {
+		case paneConfig:
+			middle = append(middle, strings.Split(m.renderConfigPanel(filtered[m.cursor], width, rows-len(middle), theme), "\n")...)
+		case paneLog:
+			middle = append(middle, strings.Split(m.renderLogPanel(filtered[m.cursor], width, rows-len(middle), theme), "\n")...)
+		default:
+			for _, s := range m.plainDetails(filtered[m.cursor]) {
+				middle = append(middle, line(s)...)
+			}
+		}
+	}
+	if len(middle) > rows {
+		middle = middle[:rows]
+	}
+	for len(middle) < rows {
+		middle = append(middle, "")
+	}
+
+	lines := append(append(top, middle...), bottom...)
+	return lipgloss.NewStyle().Foreground(theme.NormalFg).Render(strings.Join(lines, "\n"))
+}
+
+// plainInterface describes an interface in one sentence
+func (m Model) plainInterface(iface wg.Interface) string {
+	parts := []string{iface.Name}
+	state := strings.ToLower(iface.Status.String())
+	if label := m.ops.pendingLabel(iface.Name); label != "" {
+		state += ", " + label
+	}
+	parts = append(parts, state)
+	if port := wg.ListenPort(iface, m.configs[iface.Name]); port > 0 {
+		parts = append(parts, fmt.Sprintf("port %d", port))
+	}
+	if conflict := m.conflicts[iface.Name]; conflict != "" {
+		parts = append(parts, "warning: "+conflict)
+	}
+	if iface.Status == wg.InterfaceUp {
+		var rx, tx int64
+		var latest time.Time
+		for _, p := range m.peers[iface.Name] {
+			rx += p.TransferRx
+			tx += p.TransferTx
+			if p.LatestHandshake.After(latest) {
+				latest = p.LatestHandshake
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%d peers", len(m.peers[iface.Name])))
+		if rx > 0 || tx > 0 {
+			parts = append(parts, fmt.Sprintf("received %s, sent %s", m.formatBytes(rx), m.formatBytes(tx)))
+		}
+		if !latest.IsZero() {
+			parts = append(parts, "last handshake "+fmtDur(time.Since(latest))+" ago")
+		}
+	}
+	if m.tagged[iface.Name] {
+		parts = append(parts, "tagged")
+	}
+	return strings.Join(parts, ", ") + "."
+}
+
+// plainDetails are the details panel as lines of text, a peer per line
+func (m Model) plainDetails(iface wg.Interface) []string {
+	lines := []string{"Selected " + iface.Name + ":"}
+	if iface.PublicKey != "" {
+		lines = append(lines, "Public key "+iface.PublicKey+".")
+	}
+	if summary := m.ipamSummary(iface.Name); summary != "" {
+		lines = append(lines, "Addresses "+summary+".")
+	}
+	if rate := strings.TrimSpace(m.trafficLine(iface.Name, 0)); rate != "" {
+		lines = append(lines, "Traffic "+rate+".")
+	}
+	if e, ok := m.lastEvent(iface.Name); ok {
+		lines = append(lines, "Last event at "+e.Time.Format("15:04:05")+": "+e.String()+".")
+	}
+	peers := m.peers[iface.Name]
+	if len(peers) == 0 {
+		return append(lines, "No peers.")
+	}
+	for _, p := range peers {
+		text := "Peer " + truncate(p.PublicKey, 12)
+		if p.Endpoint != "" {
+			text += ", endpoint " + p.Endpoint
+		}
+		text += ", allowed IPs " + strings.Join(p.AllowedIPs, " ")
+		text += fmt.Sprintf(", received %s, sent %s", m.formatBytes(p.TransferRx), m.formatBytes(p.TransferTx))
+		if p.LatestHandshake.IsZero() {
+			text += ", no handshake yet."
+		} else {
+			text += ", last handshake " + fmtDur(time.Since(p.LatestHandshake)) + " ago."
+		}
+		lines = append(lines, text)
+	}
+	return lines
+}
+
+// plainKeys lists the main keys the way the footer does, in words
+func (m Model) plainKeys() string {
+	keys := []string{"F1 help", "F2 theme", "F3 config"}
+	if m.policy.Permits(policy.Edit) {
+		keys = append(keys, "F4 edit")
+	}
+	keys = append(keys, "F5 refresh", "F6 filter", "F7 history", "F8 addresses", "F9 bulk")
+	if m.canToggle() {
+		keys = append(keys, "Space toggle")
+	}
+	return strings.Join(append(keys, "F10 quit"), ", ") + "."
+}
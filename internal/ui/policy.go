@@ -0,0 +1,33 @@
+package ui
+
+import "wireguard-tui/internal/policy"
+
+// allowed checks the policy before starting action a on iface, and says
+// why in a toast when it is not allowed. The client enforces the policy
+// too; this just avoids offering something that is bound to fail.
+func (m Model) allowed(a policy.Action, iface string) bool {
+	if err := m.policy.Check(a, iface); err != nil {
+		m.ops.toast(err.Error(), true)
+		return false
+	}
+	return true
+}
+
+// bulkPermitted reports whether a bulk action needs nothing the policy
+// rules out everywhere
+func (m Model) bulkPermitted(key string) bool {
+	switch key {
+	case "u":
+		return m.policy.Permits(policy.Up)
+	case "d":
+		return m.policy.Permits(policy.Down)
+	case "r":
+		return m.policy.Permits(policy.Down) && m.policy.Permits(policy.Up)
+	case "l":
+		return m.policy.Permits(policy.Reload)
+	case "e":
+		// An exported config holds the keys
+		return m.policy.Permits(policy.Secrets)
+	}
+	return true
+}
@@ -0,0 +1,356 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wireguard-tui/internal/wg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type rotateStage int
+
+const (
+	rotateConfirm rotateStage = iota
+	rotateReview
+	rotateVerifying
+	rotateTimedOut
+	rotateDone
+)
+
+// rotation walks through replacing the key pair of one interface:
+// generate, review, apply live, then watch Peer.LatestHandshake until a
+// peer completes a handshake with the new key or the window runs out.
+type rotation struct {
+	iface      wg.Interface
+	stage      rotateStage
+	oldConfig  []byte
+	newConfig  []byte
+	oldPrivate string
+	newPrivate string
+	oldPublic  string
+	newPublic  string
+	peers      []*wg.Section
+	snippetDir string
+	appliedAt  time.Time
+	deadline   time.Time
+	result     string
+	err        error
+	busy       bool
+}
+
+type rotationReadyMsg struct {
+	r   *rotation
+	err error
+}
+
+type rotationAppliedMsg struct{ err error }
+
+type rotationRolledBackMsg struct{ err error }
+
+// prepareRotationCmd generates the new key, the updated config and a
+// snippet per peer. Nothing on the system changes yet.
+func (m Model) prepareRotationCmd(r *rotation) tea.Cmd {
+	return func() tea.Msg {
+		name := r.iface.Name
+		data, err := m.client.ReadConfig(context.Background(), name)
+		if err != nil {
+			return rotationReadyMsg{err: err}
+		}
+		cfg, err := wg.ParseConfig(data)
+		if err != nil {
+			return rotationReadyMsg{err: err}
+		}
+		if err := cfg.Validate(); err != nil {
+			return rotationReadyMsg{err: fmt.Errorf("fix the config before rotating: %v", err)}
+		}
+
+		next := *r
+		next.oldConfig = data
+		next.peers = cfg.Peers
+		next.oldPrivate = cfg.Interface.Get("PrivateKey")
+		if next.oldPublic, err = wg.PublicKey(next.oldPrivate); err != nil {
+			return rotationReadyMsg{err: err}
+		}
+		if next.newPrivate, next.newPublic, err = wg.GenerateKeyPair(); err != nil {
+			return rotationReadyMsg{err: err}
+		}
+		if next.newConfig, err = wg.SetInterfaceValue(data, "PrivateKey", next.newPrivate); err != nil {
+			return rotationReadyMsg{err: err}
+		}
+		if next.snippetDir, err = writePeerSnippets(&next, m.stateDir); err != nil {
+			return rotationReadyMsg{err: err}
+		}
+		next.stage = rotateReview
+		next.busy = false
+		return rotationReadyMsg{r: &next}
+	}
+}
+
+// writePeerSnippets saves, for every peer, the change it has to make to
+// keep talking to us
+func writePeerSnippets(r *rotation, stateDir string) (string, error) {
+	host, _ := os.Hostname()
+	now := time.Now()
+	dir := filepath.Join(stateDir, "rotations", fmt.Sprintf("%s-%s", r.iface.Name, now.Format("20060102-150405")))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	for i, p := range r.peers {
+		snippet := fmt.Sprintf(`# %s on %s rotated its key pair at %s.
+# On peer %s (AllowedIPs here: %s),
+# in the [Peer] section that currently reads
+#   PublicKey = %s
+# replace that line with:
+PublicKey = %s
+`, r.iface.Name, host, now.Format(time.RFC3339), p.Get("PublicKey"), p.Get("AllowedIPs"), r.oldPublic, r.newPublic)
+		path := filepath.Join(dir, fmt.Sprintf("peer-%d.conf", i+1))
+		if err := os.WriteFile(path, []byte(snippet), 0600); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// applyRotationCmd switches the running interface to the new key and
+// persists it. A down interface only gets the new config.
+func (m Model) applyRotationCmd(r *rotation) tea.Cmd {
+	name := r.iface.Name
+	return m.submit(&operation{iface: name, verb: "rotating key", run: func(ctx context.Context, _ io.Writer) error {
+		if err := m.writeConfig(ctx, name, "rotate key", r.newConfig); err != nil {
+			return err
+		}
+		if r.iface.Status != wg.InterfaceUp {
+			return nil
+		}
+		if err := m.client.SetPrivateKey(ctx, name, r.newPrivate); err != nil {
+			// A cancelled rotation is still rolled back
+			if ctx.Err() != nil {
+				err = errCancelled
+			}
+			if rbErr := m.writeConfig(context.WithoutCancel(ctx), name, "rotate key rollback", r.oldConfig); rbErr != nil {
+				return fmt.Errorf("%w (restoring config also failed: %v)", err, rbErr)
+			}
+			return fmt.Errorf("%w (previous config restored)", err)
+		}
+		return nil
+	}, notify: func(err error) tea.Msg {
+		return rotationAppliedMsg{err}
+	}})
+}
+
+func (m Model) rollbackRotationCmd(r *rotation) tea.Cmd {
+	name := r.iface.Name
+	return m.submit(&operation{iface: name, verb: "rolling back key", run: func(ctx context.Context, _ io.Writer) error {
+		if err := m.client.SetPrivateKey(ctx, name, r.oldPrivate); err != nil {
+			return err
+		}
+		return m.writeConfig(ctx, name, "rotate key rollback", r.oldConfig)
+	}, notify: func(err error) tea.Msg {
+		return rotationRolledBackMsg{err}
+	}})
+}
+
+// checkRotation looks for the first handshake made after the new key was
+// applied; it runs on every data refresh
+func (m *Model) checkRotation() {
+	r := m.rotation
+	if r == nil || r.stage != rotateVerifying {
+		return
+	}
+	for _, p := range m.peers[r.iface.Name] {
+		if p.LatestHandshake.After(r.appliedAt) {
+			r.stage = rotateDone
+			r.result = fmt.Sprintf("Peer %s completed a handshake with the new key after %s.",
+				truncate(p.PublicKey, 12), fmtDur(p.LatestHandshake.Sub(r.appliedAt)))
+			return
+		}
+	}
+	if time.Now().After(r.deadline) {
+		r.stage = rotateTimedOut
+	}
+}
+
+func (m Model) updateRotation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	r := m.rotation
+	if r.busy {
+		if msg.String() == "esc" {
+			m.ops.cancel(r.iface.Name)
+		}
+		return m, nil
+	}
+	key := msg.String()
+	switch r.stage {
+	case rotateConfirm:
+		switch key {
+		case "enter", "y":
+			r.busy = true
+			return m, m.prepareRotationCmd(r)
+		case "esc", "n", "q":
+			m.rotation = nil
+		}
+	case rotateReview:
+		switch key {
+		case "a":
+			r.busy = true
+			return m, m.applyRotationCmd(r)
+		case "esc", "q":
+			os.RemoveAll(r.snippetDir)
+			m.rotation = nil
+		}
+	case rotateVerifying, rotateTimedOut:
+		switch key {
+		case "b":
+			r.busy = true
+			return m, m.rollbackRotationCmd(r)
+		case "k":
+			r.stage = rotateDone
+			r.result = "Kept the new key without a confirmed handshake."
+		}
+	case rotateDone:
+		m.rotation = nil
+	}
+	return m, nil
+}
+
+func (m Model) updateRotationResult(msg tea.Msg) (tea.Model, tea.Cmd) {
+	r := m.rotation
+	if r == nil {
+		return m, nil
+	}
+	r.busy = false
+	switch msg := msg.(type) {
+	case rotationReadyMsg:
+		if msg.err != nil {
+			m.rotation = nil
+			m.err = msg.err
+			return m, nil
+		}
+		m.rotation = msg.r
+	case rotationAppliedMsg:
+		if msg.err != nil {
+			r.stage = rotateDone
+			r.err = msg.err
+			break
+		}
+		if r.iface.Status != wg.InterfaceUp {
+			r.stage = rotateDone
+			r.result = fmt.Sprintf("New key saved; it takes effect when %s is brought up.", r.iface.Name)
+			break
+		}
+		r.stage = rotateVerifying
+		r.appliedAt = time.Now()
+		r.deadline = r.appliedAt.Add(m.rotateWindow)
+	case rotationRolledBackMsg:
+		r.stage = rotateDone
+		if msg.err != nil {
+			r.err = fmt.Errorf("rollback failed: %v", msg.err)
+		} else {
+			r.result = "Rolled back to the previous key pair."
+		}
+	}
+	return m, m.refresh()
+}
+
+func (m Model) renderRotationDialog(width, height int, theme Theme) string {
+	r := m.rotation
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sLabel := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sAccent := lipgloss.NewStyle().Foreground(theme.HeaderBg).Bold(true)
+	sOK := lipgloss.NewStyle().Foreground(theme.GoodFg).Bold(true)
+	sBad := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+
+	boxWidth := width - 8
+	if boxWidth > 96 {
+		boxWidth = 96
+	}
+	inner := boxWidth - 6
+
+	lines := []string{sTitle.Render("Rotate key pair of " + r.iface.Name), ""}
+	switch r.stage {
+	case rotateConfirm:
+		lines = append(lines,
+			sValue.Render("A new private key will be generated. Every peer has to be told the"),
+			sValue.Render("new public key, or it will stop accepting handshakes from us."),
+			"",
+		)
+		if r.busy {
+			lines = append(lines, sDim.Render("Generating…"))
+		} else {
+			lines = append(lines, sKey.Render("Enter")+" Generate new key  "+sKey.Render("Esc")+" Cancel")
+		}
+
+	case rotateReview:
+		lines = append(lines,
+			sLabel.Render("Old public key: ")+sDim.Render(r.oldPublic),
+			sLabel.Render("New public key: ")+sAccent.Render(r.newPublic),
+			"",
+			sLabel.Render(fmt.Sprintf("%d peer(s) must replace their PublicKey for us with:", len(r.peers))),
+			sAccent.Render("  PublicKey = "+r.newPublic),
+		)
+		for _, p := range r.peers {
+			lines = append(lines, sValue.Render(truncate(fmt.Sprintf("  • %s  (%s)", p.Get("PublicKey"), p.Get("AllowedIPs")), inner)))
+		}
+		lines = append(lines,
+			sDim.Render(truncate("Snippets for each peer: "+r.snippetDir, inner)),
+			"",
+		)
+		if r.iface.Status == wg.InterfaceUp {
+			lines = append(lines, sDim.Render(fmt.Sprintf("Applying runs `wg set %s private-key`, updates the config and then waits", r.iface.Name)),
+				sDim.Render(fmt.Sprintf("up to %s for a handshake before offering a rollback.", m.rotateWindow)), "")
+		} else {
+			lines = append(lines, sDim.Render("The interface is down, so only the config file is updated."), "")
+		}
+		if r.busy {
+			lines = append(lines, sDim.Render("Applying… Esc to cancel"))
+		} else {
+			lines = append(lines, sKey.Render("A")+" Apply new key  "+sKey.Render("Esc")+" Cancel (nothing changed)")
+		}
+
+	case rotateVerifying, rotateTimedOut:
+		if r.stage == rotateVerifying {
+			left := time.Until(r.deadline)
+			if left < 0 {
+				left = 0
+			}
+			lines = append(lines, sValue.Render(fmt.Sprintf("New key active since %s. Waiting for a handshake (%s left)…",
+				r.appliedAt.Format("15:04:05"), fmtDur(left))))
+		} else {
+			lines = append(lines, sBad.Render(fmt.Sprintf("No handshake within %s of applying the new key.", m.rotateWindow)),
+				sValue.Render("Peers may not have been updated yet. Roll back to restore connectivity."))
+		}
+		lines = append(lines, "")
+		for _, p := range m.peers[r.iface.Name] {
+			status := sDim.Render("waiting")
+			if p.LatestHandshake.After(r.appliedAt) {
+				status = sOK.Render("handshake ✓")
+			}
+			lines = append(lines, sValue.Render(fmt.Sprintf("  %-14s ", truncate(p.PublicKey, 12)))+status)
+		}
+		lines = append(lines, "")
+		if r.busy {
+			lines = append(lines, sDim.Render("Rolling back… Esc to cancel"))
+		} else {
+			lines = append(lines, sKey.Render("B")+" Roll back to old key  "+sKey.Render("K")+" Keep new key")
+		}
+
+	case rotateDone:
+		if r.err != nil {
+			lines = append(lines, sBad.Render(truncate(r.err.Error(), inner)))
+		} else {
+			lines = append(lines, sOK.Render(r.result))
+		}
+		lines = append(lines, "", sDim.Render("Press any key to close"))
+	}
+
+	return m.dialogBox(boxWidth, theme).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
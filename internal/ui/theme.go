@@ -1,9 +1,17 @@
 package ui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 type Theme struct {
-	Name           string
+	Name string
+	// Path is the file the theme was loaded from, empty for built-ins
+	Path           string `toml:"-"`
 	HeaderBg       lipgloss.Color
 	HeaderFg       lipgloss.Color
 	ColumnHeaderBg lipgloss.Color // implicit or same as Header? Using separate might be nice
@@ -16,6 +24,12 @@ type Theme struct {
 	KeyFg          lipgloss.Color
 	DescBg         lipgloss.Color
 	DescFg         lipgloss.Color
+	// GoodFg, WarnFg and BadFg mark state, such as up, warning and down
+	GoodFg lipgloss.Color
+	WarnFg lipgloss.Color
+	BadFg  lipgloss.Color
+	// Mono themes have no colors and use bold and reverse video instead
+	Mono bool `toml:"-"`
 }
 
 var Themes = []Theme{
@@ -34,6 +48,9 @@ var Themes = []Theme{
 		KeyFg:          lipgloss.Color("0"),   // Black
 		DescBg:         lipgloss.Color("2"),   // Green
 		DescFg:         lipgloss.Color("0"),   // Black
+		GoodFg:         lipgloss.Color("10"),
+		WarnFg:         lipgloss.Color("11"),
+		BadFg:          lipgloss.Color("9"),
 	},
 	// Dracula (Dark Pulse)
 	{
@@ -50,6 +67,9 @@ var Themes = []Theme{
 		KeyFg:          lipgloss.Color("235"), // Dark
 		DescBg:         lipgloss.Color("62"),  // Purple
 		DescFg:         lipgloss.Color("255"), // White
+		GoodFg:         lipgloss.Color("10"),
+		WarnFg:         lipgloss.Color("11"),
+		BadFg:          lipgloss.Color("9"),
 	},
 	// Solarized Light
 	{
@@ -66,6 +86,9 @@ var Themes = []Theme{
 		KeyFg:          lipgloss.Color("255"), // White
 		DescBg:         lipgloss.Color("136"), // Yellow
 		DescFg:         lipgloss.Color("230"), // Base3
+		GoodFg:         lipgloss.Color("10"),
+		WarnFg:         lipgloss.Color("11"),
+		BadFg:          lipgloss.Color("9"),
 	},
 	// Nord (Arctic)
 	{
@@ -82,6 +105,9 @@ var Themes = []Theme{
 		KeyFg:          lipgloss.Color("232"),
 		DescBg:         lipgloss.Color("237"),
 		DescFg:         lipgloss.Color("255"),
+		GoodFg:         lipgloss.Color("10"),
+		WarnFg:         lipgloss.Color("11"),
+		BadFg:          lipgloss.Color("9"),
 	},
 	// Tokyo Night
 	{
@@ -98,5 +124,155 @@ var Themes = []Theme{
 		KeyFg:          lipgloss.Color("232"),
 		DescBg:         lipgloss.Color("236"),
 		DescFg:         lipgloss.Color("176"),
+		GoodFg:         lipgloss.Color("10"),
+		WarnFg:         lipgloss.Color("11"),
+		BadFg:          lipgloss.Color("9"),
+	},
+	// Okabe-Ito, whose colors stay apart with any kind of color blindness.
+	// State is blue against vermillion rather than green against red.
+	{
+		Name:           "Okabe-Ito",
+		HeaderBg:       lipgloss.Color("#0072b2"), // Blue
+		HeaderFg:       lipgloss.Color("#ffffff"),
+		ColumnHeaderBg: lipgloss.Color("#262626"),
+		ColumnHeaderFg: lipgloss.Color("#56b4e9"), // Sky blue
+		SelectedBg:     lipgloss.Color("#e69f00"), // Orange
+		SelectedFg:     lipgloss.Color("#000000"),
+		NormalFg:       lipgloss.Color("#e4e4e4"),
+		DimFg:          lipgloss.Color("#8a8a8a"),
+		KeyBg:          lipgloss.Color("#f0e442"), // Yellow
+		KeyFg:          lipgloss.Color("#000000"),
+		DescBg:         lipgloss.Color("#0072b2"),
+		DescFg:         lipgloss.Color("#ffffff"),
+		GoodFg:         lipgloss.Color("#56b4e9"),
+		WarnFg:         lipgloss.Color("#f0e442"),
+		BadFg:          lipgloss.Color("#d55e00"), // Vermillion
 	},
+	// Okabe-Ito on a light terminal
+	{
+		Name:           "Okabe-Ito Light",
+		HeaderBg:       lipgloss.Color("#0072b2"),
+		HeaderFg:       lipgloss.Color("#ffffff"),
+		ColumnHeaderBg: lipgloss.Color("#e4e4e4"),
+		ColumnHeaderFg: lipgloss.Color("#0072b2"),
+		SelectedBg:     lipgloss.Color("#0072b2"),
+		SelectedFg:     lipgloss.Color("#ffffff"),
+		NormalFg:       lipgloss.Color("#1c1c1c"),
+		DimFg:          lipgloss.Color("#6c6c6c"),
+		KeyBg:          lipgloss.Color("#e69f00"),
+		KeyFg:          lipgloss.Color("#000000"),
+		DescBg:         lipgloss.Color("#0072b2"),
+		DescFg:         lipgloss.Color("#ffffff"),
+		GoodFg:         lipgloss.Color("#0072b2"),
+		WarnFg:         lipgloss.Color("#cc79a7"), // Reddish purple
+		BadFg:          lipgloss.Color("#d55e00"),
+	},
+	// Monochrome (High Contrast): the terminal's own colors, with state
+	// shown by symbols and bold. NO_COLOR picks it.
+	{
+		Name: "Monochrome",
+		Mono: true,
+	},
+}
+
+// ThemeIndex finds a theme by name, ignoring case. The empty name is the
+// first theme.
+func ThemeIndex(themes []Theme, name string) (int, bool) {
+	if name == "" {
+		return 0, true
+	}
+	for i, t := range themes {
+		if strings.EqualFold(t.Name, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// themeColor is one color of a theme, named as in theme files
+type themeColor struct {
+	name  string
+	color *lipgloss.Color
+}
+
+// colors lists the colors of t in the order of the struct
+func (t *Theme) colors() []themeColor {
+	return []themeColor{
+		{"HeaderBg", &t.HeaderBg},
+		{"HeaderFg", &t.HeaderFg},
+		{"ColumnHeaderBg", &t.ColumnHeaderBg},
+		{"ColumnHeaderFg", &t.ColumnHeaderFg},
+		{"SelectedBg", &t.SelectedBg},
+		{"SelectedFg", &t.SelectedFg},
+		{"NormalFg", &t.NormalFg},
+		{"DimFg", &t.DimFg},
+		{"KeyBg", &t.KeyBg},
+		{"KeyFg", &t.KeyFg},
+		{"DescBg", &t.DescBg},
+		{"DescFg", &t.DescFg},
+		{"GoodFg", &t.GoodFg},
+		{"WarnFg", &t.WarnFg},
+		{"BadFg", &t.BadFg},
+	}
+}
+
+// monoThemes picks the themes without colors out of themes
+func monoThemes(themes []Theme) []Theme {
+	var mono []Theme
+	for _, t := range themes {
+		if t.Mono {
+			mono = append(mono, t)
+		}
+	}
+	if len(mono) == 0 {
+		mono = append(mono, Theme{Name: "Monochrome", Mono: true})
+	}
+	return mono
+}
+
+// fill styles text on a background, which mono themes show in reverse
+// video instead
+func (t Theme) fill(fg, bg lipgloss.Color) lipgloss.Style {
+	if t.Mono {
+		return lipgloss.NewStyle().Reverse(true)
+	}
+	return lipgloss.NewStyle().Foreground(fg).Background(bg)
+}
+
+// The 16 basic colors as xterm draws them
+var ansi16 = []string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// HexColor turns a theme color, which is an xterm-256 index or a hex
+// value, into #rrggbb. It is empty for anything else.
+func HexColor(c lipgloss.Color) string {
+	s := string(c)
+	if hexColor.MatchString(s) {
+		if len(s) == 4 {
+			return strings.ToLower(fmt.Sprintf("#%c%c%c%c%c%c", s[1], s[1], s[2], s[2], s[3], s[3]))
+		}
+		return strings.ToLower(s)
+	}
+	n, err := strconv.Atoi(s)
+	switch {
+	case err != nil || n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return ansi16[n]
+	case n < 232:
+		// 6x6x6 color cube
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		g := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", g, g, g)
+	}
 }
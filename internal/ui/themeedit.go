@@ -0,0 +1,217 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// themeEditorWidth is the width of the editor panel, the dashboard
+// taking the rest
+const themeEditorWidth = 44
+
+// themeEditor edits a copy of the current theme. The whole screen is
+// drawn in that copy while the editor is open, so changes show at once.
+type themeEditor struct {
+	theme  Theme
+	base   Theme
+	cursor int
+	// channel is the one of red, green and blue that ←→ adjust
+	channel int
+	// input is set while typing a hex value, or naming the theme when
+	// saving
+	input  *string
+	saving bool
+	err    string
+}
+
+func newThemeEditor(base Theme) *themeEditor {
+	t := base
+	t.Name += " Custom"
+	t.Path = ""
+	return &themeEditor{theme: t, base: base}
+}
+
+// rgb splits a theme color into its channels
+func rgb(c lipgloss.Color) [3]int {
+	var v [3]int
+	fmt.Sscanf(HexColor(c), "#%02x%02x%02x", &v[0], &v[1], &v[2])
+	return v
+}
+
+func (m Model) updateThemeEditor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := m.themeEdit
+	colors := v.theme.colors()
+	current := colors[v.cursor].color
+	if v.input != nil {
+		switch msg.String() {
+		case "esc":
+			v.input, v.saving, v.err = nil, false, ""
+		case "enter":
+			text := strings.TrimSpace(*v.input)
+			if v.saving {
+				return m.saveTheme(text)
+			}
+			if !strings.HasPrefix(text, "#") {
+				text = "#" + text
+			}
+			color, ok := parseColor(text)
+			if !ok {
+				v.err = fmt.Sprintf("%q is not a hex color", text)
+				return m, nil
+			}
+			*current = lipgloss.Color(HexColor(color))
+			v.input, v.err = nil, ""
+		case "backspace":
+			if s := *v.input; len(s) > 0 {
+				*v.input = s[:len(s)-1]
+			}
+		default:
+			if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+				*v.input += string(msg.Runes)
+			}
+		}
+		return m, nil
+	}
+
+	v.err = ""
+	step := 0
+	switch msg.String() {
+	case "esc", "q":
+		m.themeEdit = nil
+	case "up", "k":
+		if v.cursor > 0 {
+			v.cursor--
+		}
+	case "down", "j":
+		if v.cursor < len(colors)-1 {
+			v.cursor++
+		}
+	case "tab":
+		v.channel = (v.channel + 1) % 3
+	case "shift+tab":
+		v.channel = (v.channel + 2) % 3
+	case "left", "h":
+		step = -8
+	case "right", "l":
+		step = 8
+	case "shift+left", "H":
+		step = -1
+	case "shift+right", "L":
+		step = 1
+	case "enter", "#":
+		hex := HexColor(*current)
+		v.input = &hex
+	case "u":
+		*current = *v.base.colors()[v.cursor].color
+	case "s", "ctrl+s":
+		if len(m.themeDirs) == 0 {
+			v.err = "No theme directory to save to"
+			return m, nil
+		}
+		name := v.theme.Name
+		v.input, v.saving = &name, true
+	}
+	if step != 0 {
+		c := rgb(*current)
+		c[v.channel] = min(max(c[v.channel]+step, 0), 255)
+		*current = lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2]))
+	}
+	return m, nil
+}
+
+// saveTheme writes the edited theme as a new file in the user's theme
+// dir and switches to it
+func (m Model) saveTheme(name string) (tea.Model, tea.Cmd) {
+	v := m.themeEdit
+	if name == "" {
+		v.err = "The theme needs a name"
+		return m, nil
+	}
+	if _, ok := ThemeIndex(m.themes, name); ok {
+		v.err = fmt.Sprintf("There already is a theme named %s", name)
+		return m, nil
+	}
+	t := v.theme
+	t.Name = name
+	path := filepath.Join(m.themeDirs[len(m.themeDirs)-1], themeFileName(name))
+	if err := WriteThemeFile(path, t); err != nil {
+		v.err = err.Error()
+		return m, nil
+	}
+	m.themeStamp = ""
+	m.reloadThemes()
+	if i, ok := ThemeIndex(m.themes, name); ok {
+		m.themeIndex = i
+	}
+	m.themeEdit = nil
+	m.ops.toast("Saved theme "+name+" to "+path, false)
+	return m, nil
+}
+
+func (m Model) renderThemeEditor(height int, theme Theme) string {
+	v := m.themeEdit
+	sTitle := lipgloss.NewStyle().Foreground(theme.ColumnHeaderFg).Bold(true)
+	sValue := lipgloss.NewStyle().Foreground(theme.NormalFg)
+	sSel := theme.fill(theme.SelectedFg, theme.SelectedBg)
+	sKey := theme.fill(theme.KeyFg, theme.KeyBg).Bold(true).Padding(0, 1)
+	sDim := lipgloss.NewStyle().Foreground(theme.DimFg)
+	sError := lipgloss.NewStyle().Foreground(theme.BadFg).Bold(true)
+	inner := themeEditorWidth - 4
+
+	lines := []string{sTitle.Render(truncate("Theme editor, "+v.base.Name, inner))}
+	for i, c := range v.theme.colors() {
+		swatch := lipgloss.NewStyle().Background(*c.color).Render("    ")
+		label := fmt.Sprintf(" %-15s %-8s", c.name, HexColor(*c.color))
+		if i != v.cursor {
+			lines = append(lines, swatch+sValue.Render(label))
+			continue
+		}
+		lines = append(lines, swatch+sSel.Render(label+strings.Repeat(" ", inner-4-len(label))))
+		// The channels of the selected color, the one ←→ adjust standing
+		// out with a bar of its level
+		var channels []string
+		for j, level := range rgb(*c.color) {
+			text := fmt.Sprintf("%c %3d", "RGB"[j], level)
+			if j == v.channel {
+				channels = append(channels, sKey.Render(text))
+			} else {
+				channels = append(channels, sDim.Padding(0, 1).Render(text))
+			}
+		}
+		level := rgb(*c.color)[v.channel] * 8 / 255
+		bar := strings.Repeat("█", level) + strings.Repeat("░", 8-level)
+		lines = append(lines, "    "+strings.Join(channels, " ")+" "+sDim.Render(bar))
+	}
+	lines = append(lines, "")
+
+	switch {
+	case v.input != nil && v.saving:
+		lines = append(lines, sValue.Render("Save as: ")+sSel.Render(*v.input+"_"))
+	case v.input != nil:
+		lines = append(lines, sValue.Render("Hex: ")+sSel.Render(*v.input+"_"))
+	case v.err != "":
+		lines = append(lines, sError.Render(truncate(v.err, inner)))
+	default:
+		lines = append(lines, sDim.Render("The dashboard shows your changes"))
+	}
+	if v.input != nil {
+		lines = append(lines, sKey.Render("Enter")+" OK  "+sKey.Render("Esc")+" Cancel")
+	} else {
+		lines = append(lines,
+			sKey.Render("↑↓")+" Color  "+sKey.Render("Tab")+" RGB  "+sKey.Render("←→")+" Adjust",
+			sKey.Render("Enter")+" Hex  "+sKey.Render("U")+" Undo  "+sKey.Render("S")+" Save as",
+			sKey.Render("Esc")+" Close without saving",
+		)
+	}
+	return lipgloss.NewStyle().
+		Border(m.border(lipgloss.DoubleBorder())).
+		BorderForeground(theme.KeyBg).
+		Padding(0, 1).
+		Width(themeEditorWidth - 2).
+		Height(max(height-2, 0)).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
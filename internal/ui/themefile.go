@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme files are TOML or JSON with one key per color, named as the
+// fields of Theme:
+//
+//	Name = "House"
+//	HeaderBg = "#005f87"
+//	HeaderFg = "#ffffff"
+//	...
+//
+// Colors are hex, which terminals without true color get the nearest
+// 256 or 16 color of, or xterm-256 indices. Name defaults to the file
+// name, and the state colors, which came later, to green, yellow and red.
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+var stateColors = map[string]lipgloss.Color{"GoodFg": "10", "WarnFg": "11", "BadFg": "9"}
+
+// LoadThemeFile reads a theme file, which has to set every color
+func LoadThemeFile(path string) (Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, fmt.Errorf("failed to load theme %s: %v", path, err)
+	}
+	keys := make(map[string]any)
+	switch filepath.Ext(path) {
+	case ".toml":
+		err = toml.Unmarshal(data, &keys)
+	case ".json":
+		err = json.Unmarshal(data, &keys)
+	default:
+		return Theme{}, fmt.Errorf("theme %s: expected a .toml or .json file", path)
+	}
+	if err != nil {
+		return Theme{}, fmt.Errorf("failed to load theme %s: %v", path, err)
+	}
+
+	base := filepath.Base(path)
+	t := Theme{Name: strings.TrimSuffix(base, filepath.Ext(base)), Path: path}
+	if name, ok := keys["Name"]; ok {
+		t.Name = fmt.Sprint(name)
+		delete(keys, "Name")
+	}
+	if strings.TrimSpace(t.Name) == "" {
+		return Theme{}, fmt.Errorf("theme %s: empty name", path)
+	}
+	var missing []string
+	for _, c := range t.colors() {
+		value, ok := keys[c.name]
+		if !ok {
+			if color, ok := stateColors[c.name]; ok {
+				*c.color = color
+			} else {
+				missing = append(missing, c.name)
+			}
+			continue
+		}
+		delete(keys, c.name)
+		color, ok := parseColor(value)
+		if !ok {
+			return Theme{}, fmt.Errorf("theme %s: %s is %v, expected #rrggbb or 0-255", path, c.name, value)
+		}
+		*c.color = color
+	}
+	if len(missing) > 0 {
+		return Theme{}, fmt.Errorf("theme %s: missing %s", path, strings.Join(missing, ", "))
+	}
+	for key := range keys {
+		return Theme{}, fmt.Errorf("theme %s: unknown key %s", path, key)
+	}
+	return t, nil
+}
+
+// parseColor takes a hex string or an xterm-256 index, given as a
+// number or a string
+func parseColor(value any) (lipgloss.Color, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return "", false
+	}
+	if hexColor.MatchString(s) {
+		return lipgloss.Color(s), true
+	}
+	n, err := strconv.Atoi(s)
+	return lipgloss.Color(s), err == nil && n >= 0 && n <= 255
+}
+
+// WriteThemeFile saves t as a TOML theme file, refusing to replace one
+func WriteThemeFile(path string, t Theme) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to save theme: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to save theme: %v", err)
+	}
+	if err := toml.NewEncoder(f).Encode(t); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to save theme %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// themeFileName turns a theme name into a file name, e.g. "House Dark"
+// into house-dark.toml
+func themeFileName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "theme.toml"
+	}
+	return b.String() + ".toml"
+}
+
+// themeFiles lists the theme files in dirs, in order and by name within
+// a dir. Dirs that do not exist are skipped.
+func themeFiles(dirs []string) []string {
+	var files []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".toml" || ext == ".json") {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	return files
+}
+
+// LoadThemes returns the built-in themes followed by those in dirs. A
+// file replaces an earlier theme of the same name.
+func LoadThemes(dirs ...string) ([]Theme, error) {
+	themes := slices.Clone(Themes)
+	for _, path := range themeFiles(dirs) {
+		t, err := LoadThemeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := ThemeIndex(themes, t.Name); ok {
+			themes[i] = t
+		} else {
+			themes = append(themes, t)
+		}
+	}
+	return themes, nil
+}
+
+// themeStamp changes whenever a theme file in dirs is added, removed or
+// written, which is cheap enough to check on every tick
+func themeStamp(dirs []string) string {
+	var b strings.Builder
+	for _, path := range themeFiles(dirs) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// reloadThemes picks up theme files that changed since the last tick,
+// staying on the current theme by name. A broken file is reported and
+// the themes in use are kept until it is fixed.
+func (m *Model) reloadThemes() {
+	stamp := themeStamp(m.themeDirs)
+	if stamp == m.themeStamp {
+		return
+	}
+	m.themeStamp = stamp
+	themes, err := LoadThemes(m.themeDirs...)
+	if err != nil {
+		m.ops.toast(err.Error(), true)
+		return
+	}
+	if m.noColor {
+		themes = monoThemes(themes)
+	}
+	if i, ok := ThemeIndex(themes, m.themes[m.themeIndex].Name); ok {
+		m.themeIndex = i
+	} else {
+		m.themeIndex = 0
+	}
+	m.themes = themes
+}
@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"wireguard-tui/internal/monitor"
+)
+
+// How much history each refresh brings along
+const (
+	trafficPoints = 120
+	eventCount    = 50
+)
+
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// snapshot reads from the daemon when one is attached, and polls directly
+// when there is none or it stops answering. The daemon polls on its own
+// schedule, and only polls for us when fresh is set.
+func (h *Host) snapshot(ctx context.Context, fresh bool, points, events int) (*monitor.Snapshot, bool, error) {
+	if h.Daemon != nil {
+		// A daemon that answers at all knows better than we do
+		if snap, err := h.Daemon.Snapshot(ctx, fresh, points, events); err == nil || snap != nil {
+			return snap, true, err
+		}
+	}
+	snap, err := h.Collector.Snapshot(ctx, true, points, events)
+	return snap, false, err
+}
+
+// rates turns counter samples into bytes per second. A counter that went
+// backwards means the interface was recreated.
+func rates(points []monitor.Point) []float64 {
+	var out []float64
+	for i := 1; i < len(points); i++ {
+		dt := points[i].Time.Sub(points[i-1].Time).Seconds()
+		d := points[i].Rx + points[i].Tx - points[i-1].Rx - points[i-1].Tx
+		if dt <= 0 || d < 0 {
+			d = 0
+		}
+		if dt <= 0 {
+			dt = 1
+		}
+		out = append(out, float64(d)/dt)
+	}
+	return out
+}
+
+func sparkline(values []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	var peak float64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if peak > 0 {
+			i = int(v / peak * float64(len(sparkBars)-1))
+		}
+		b.WriteRune(sparkBars[i])
+	}
+	return b.String()
+}
+
+// trafficLine summarizes the history of an interface, or is empty until
+// there are two points to compare
+func (m Model) trafficLine(name string, width int) string {
+	points := m.traffic[name]
+	if len(points) < 2 {
+		return ""
+	}
+	a, b := points[len(points)-2], points[len(points)-1]
+	dt := b.Time.Sub(a.Time).Seconds()
+	if dt <= 0 {
+		return ""
+	}
+	rate := func(d int64) string {
+		return m.formatBytes(int64(float64(max(d, 0))/dt)) + "/s"
+	}
+	summary := fmt.Sprintf(" Rx:%s Tx:%s", rate(b.Rx-a.Rx), rate(b.Tx-a.Tx))
+	return sparkline(rates(points), width-len(summary)) + summary
+}
+
+// lastEvent returns the newest event about an interface
+func (m Model) lastEvent(name string) (monitor.Event, bool) {
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if m.events[i].Interface == name {
+			return m.events[i], true
+		}
+	}
+	return monitor.Event{}, false
+}
+
+// announceEvents toasts events that happened since the last refresh.
+// History the daemon collected before we attached is only shown, not
+// announced.
+func (m *Model) announceEvents(events []monitor.Event) {
+	for _, e := range events {
+		if e.Time.After(m.eventsSeen) {
+			m.ops.toast(e.String(), m.alerts.Match(e))
+			m.eventsSeen = e.Time
+		}
+	}
+}
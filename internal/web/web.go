@@ -0,0 +1,191 @@
+// Package web serves a read-only dashboard of the interface and peer
+// tables and the traffic history. The page is a single embedded file with
+// no external assets, so it works on networks without internet access, and
+// it is kept current with server-sent events.
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wireguard-tui/internal/monitor"
+	"wireguard-tui/internal/ui"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+//go:embed dashboard.html
+var dashboard string
+
+var page = template.Must(template.New("dashboard").Parse(dashboard))
+
+// How much history the page gets: an hour at the daemon's resolution
+const (
+	historyPoints = 720
+	eventCount    = 50
+)
+
+// Server serves the dashboard
+type Server struct {
+	Source monitor.Source
+	// Themes can be picked from. Defaults to ui.Themes.
+	Themes []ui.Theme
+	// Theme is the name of the theme shown first
+	Theme string
+	// Interval is how often to push a snapshot when Source is not a
+	// collector in this process, which would say when it has polled
+	Interval time.Duration
+	// Stopped is told why serving stopped. Defaults to logging it, which
+	// draws over a TUI in the same process.
+	Stopped func(error)
+}
+
+// Handler returns the dashboard routes. There is nothing but GETs.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("GET /stream", s.stream)
+	return mux
+}
+
+// theme is a ui.Theme as CSS colors
+type theme struct {
+	Name string            `json:"name"`
+	Vars map[string]string `json:"vars"`
+}
+
+func webThemes(themes []ui.Theme) []theme {
+	if len(themes) == 0 {
+		themes = ui.Themes
+	}
+	var out []theme
+	for _, t := range themes {
+		// The page has no reverse video to stand in for colors
+		if t.Mono {
+			continue
+		}
+		out = append(out, theme{Name: t.Name, Vars: map[string]string{
+			"--header-bg":     cssColor(t.HeaderBg),
+			"--header-fg":     cssColor(t.HeaderFg),
+			"--col-header-bg": cssColor(t.ColumnHeaderBg),
+			"--col-header-fg": cssColor(t.ColumnHeaderFg),
+			"--selected-bg":   cssColor(t.SelectedBg),
+			"--selected-fg":   cssColor(t.SelectedFg),
+			"--normal-fg":     cssColor(t.NormalFg),
+			"--dim-fg":        cssColor(t.DimFg),
+			"--key-bg":        cssColor(t.KeyBg),
+			"--key-fg":        cssColor(t.KeyFg),
+			"--desc-bg":       cssColor(t.DescBg),
+			"--desc-fg":       cssColor(t.DescFg),
+		}})
+	}
+	return out
+}
+
+// cssColor turns a terminal color into a CSS one
+func cssColor(c lipgloss.Color) string {
+	if hex := ui.HexColor(c); hex != "" {
+		return hex
+	}
+	return "inherit"
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	themes, err := json.Marshal(webThemes(s.Themes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	page.Execute(w, map[string]any{
+		"Themes":     template.JS(themes),
+		"Theme":      s.Theme,
+		"StaleAfter": monitor.StaleAfter.String(),
+	})
+}
+
+// updates signals whenever there is something new to show
+func (s *Server) updates() (<-chan struct{}, func()) {
+	if c, ok := s.Source.(*monitor.Collector); ok {
+		return c.Subscribe()
+	}
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ch := make(chan struct{}, 1)
+	t := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, func() {
+		t.Stop()
+		close(done)
+	}
+}
+
+// stream sends a snapshot right away and then after every poll
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	updates, stop := s.updates()
+	defer stop()
+	for {
+		snap, err := s.Source.Snapshot(r.Context(), false, historyPoints, eventCount)
+		if err != nil {
+			fmt.Fprintf(w, "event: failure\ndata: %s\n\n", strconv.Quote(err.Error()))
+		} else if data, err := json.Marshal(snap); err == nil {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updates:
+		}
+	}
+}
+
+// Serve listens on addr and serves the dashboard in the background. Only
+// listening can fail here; later errors go to Stopped.
+func (s *Server) Serve(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", addr, err)
+	}
+	go func() {
+		err := http.Serve(l, s.Handler())
+		if s.Stopped != nil {
+			s.Stopped(err)
+		} else {
+			log.Printf("dashboard stopped: %v", err)
+		}
+	}()
+	return nil
+}
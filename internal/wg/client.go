@@ -1,6 +1,8 @@
 package wg
 
 import (
+	"context"
+	"io"
 	"time"
 )
 
@@ -10,12 +12,17 @@ type InterfaceStatus int
 const (
 	InterfaceDown InterfaceStatus = iota
 	InterfaceUp
+	// InterfaceUnknown is an interface with a config whose state could
+	// not be read, as when wg itself fails
+	InterfaceUnknown
 )
 
 func (s InterfaceStatus) String() string {
 	switch s {
 	case InterfaceUp:
 		return "UP"
+	case InterfaceUnknown:
+		return "UNKNOWN"
 	default:
 		return "DOWN"
 	}
@@ -28,6 +35,21 @@ type Interface struct {
 	ListenPort   int
 	FirewallMark int
 	Status       InterfaceStatus
+	// ConfigFile is the wg-quick config of the interface, if it has one
+	ConfigFile string
+	// ConfigStamp changes with ConfigFile, as told by its inode, size and
+	// modification time, so it need not be read again to see whether it
+	// did. Empty when the client cannot tell.
+	ConfigStamp string
+}
+
+// ConfigPath names the config of the interface as the client found it,
+// or where wg-quick would look for it
+func (i Interface) ConfigPath() string {
+	if i.ConfigFile != "" {
+		return i.ConfigFile
+	}
+	return ConfigPath(i.Name)
 }
 
 // Peer represents a connected peer
@@ -41,9 +63,53 @@ type Peer struct {
 	PersistentKeepalive int
 }
 
-// Client defines the methods for interacting with WireGuard
+// Client defines the methods for interacting with WireGuard. Every call
+// gives up when ctx is done.
 type Client interface {
-	GetInterfaces() ([]Interface, error)
-	GetPeers(interfaceName string) ([]Peer, error)
-	ToggleInterface(name string, up bool) error
+	// GetInterfaces lists running interfaces and those with a config. It
+	// may return what it could list along with an error, the configs then
+	// being InterfaceUnknown.
+	GetInterfaces(ctx context.Context) ([]Interface, error)
+	GetPeers(ctx context.Context, interfaceName string) ([]Peer, error)
+	// ToggleInterface brings an interface up or down with wg-quick. out,
+	// if not nil, receives the output of wg-quick while it runs.
+	ToggleInterface(ctx context.Context, name string, up bool, out io.Writer) error
+
+	// ReadConfig returns the raw wg-quick config of an interface
+	ReadConfig(ctx context.Context, name string) ([]byte, error)
+	// WriteConfig replaces the wg-quick config of an interface
+	WriteConfig(ctx context.Context, name string, data []byte) error
+	// SyncConfig applies the config file to a running interface
+	// without disrupting existing sessions (`wg syncconf`)
+	SyncConfig(ctx context.Context, name string) error
+	// SetPrivateKey replaces the private key of a running interface
+	SetPrivateKey(ctx context.Context, name string, privateKey string) error
+}
+
+// Wrapper is implemented by clients that add to another one, such as the
+// policy and audit wrappers. They implement every optional interface by
+// passing calls through, so whether one really works depends on the
+// client they wrap; As finds out.
+type Wrapper interface {
+	Unwrap() Client
+}
+
+// As returns c as an optional interface such as RouteInspector, if c and
+// every client it wraps implement it
+func As[T any](c Client) (T, bool) {
+	var zero T
+	t, ok := c.(T)
+	if !ok {
+		return zero, false
+	}
+	for inner := c; ; {
+		w, ok := inner.(Wrapper)
+		if !ok {
+			return t, true
+		}
+		inner = w.Unwrap()
+		if _, ok := inner.(T); !ok {
+			return zero, false
+		}
+	}
 }
@@ -0,0 +1,441 @@
+package wg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConfigDir is where wg-quick looks for interface configs
+const ConfigDir = "/etc/wireguard"
+
+// ConfigPath returns the wg-quick config file for an interface
+func ConfigPath(name string) string {
+	return filepath.Join(ConfigDir, name+".conf")
+}
+
+// ValidInterfaceName reports whether name is usable as a Linux interface
+// name, which also guarantees it is safe to use as a file name.
+func ValidInterfaceName(name string) bool {
+	if name == "" || len(name) > 15 {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_' || r == '=' || r == '+' || r == '.' || r == '-':
+		default:
+			return false
+		}
+	}
+	return name != "." && name != ".."
+}
+
+// Entry is a single `Key = Value` line of a config file
+type Entry struct {
+	Key   string
+	Value string
+	Line  int // 1-based line number in the source file
+}
+
+// Section is an [Interface] or [Peer] block
+type Section struct {
+	Name    string
+	Line    int
+	Entries []Entry
+}
+
+// Get returns the value of the first entry with the given key (case-insensitive)
+func (s *Section) Get(key string) string {
+	for _, e := range s.Entries {
+		if strings.EqualFold(e.Key, key) {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+// List splits a comma-separated value such as Address or AllowedIPs.
+// wg-quick adds up repeated lines of these keys, so every entry counts.
+func (s *Section) List(key string) []string {
+	var out []string
+	for _, e := range s.Entries {
+		if strings.EqualFold(e.Key, key) {
+			out = append(out, splitList(e.Value)...)
+		}
+	}
+	return out
+}
+
+// Config is a parsed wg-quick configuration file
+type Config struct {
+	Interface *Section
+	Peers     []*Section
+}
+
+// ConfigError describes a problem at a specific line of a config file
+type ConfigError struct {
+	Line int
+	Msg  string
+}
+
+func (e ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return e.Msg
+}
+
+// ConfigErrors collects every problem found while validating a config
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, ce := range e {
+		msgs[i] = ce.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ParseConfig parses the INI-like wg-quick format. It only fails on
+// structural problems; use Validate to check the values themselves.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	var cur *Section
+	var errs ConfigErrors
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := stripComment(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				errs = append(errs, ConfigError{lineNo, "unterminated section header"})
+				cur = nil
+				continue
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			cur = &Section{Name: name, Line: lineNo}
+			switch strings.ToLower(name) {
+			case "interface":
+				if cfg.Interface != nil {
+					errs = append(errs, ConfigError{lineNo, "duplicate [Interface] section"})
+				}
+				cfg.Interface = cur
+			case "peer":
+				cfg.Peers = append(cfg.Peers, cur)
+			default:
+				errs = append(errs, ConfigError{lineNo, fmt.Sprintf("unknown section [%s]", name)})
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			errs = append(errs, ConfigError{lineNo, fmt.Sprintf("expected 'Key = Value', got %q", line)})
+			continue
+		}
+		if cur == nil {
+			errs = append(errs, ConfigError{lineNo, "entry outside of a section"})
+			continue
+		}
+		cur.Entries = append(cur.Entries, Entry{
+			Key:   strings.TrimSpace(key),
+			Value: strings.TrimSpace(value),
+			Line:  lineNo,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return cfg, errs
+	}
+	return cfg, nil
+}
+
+// SetInterfaceValue returns data with key in the [Interface] section set
+// to value. Every other line, comments included, is kept as is; the key is
+// added below the section header if it is missing.
+func SetInterfaceValue(data []byte, key, value string) ([]byte, error) {
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Interface == nil {
+		return nil, fmt.Errorf("missing [Interface] section")
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for _, e := range cfg.Interface.Entries {
+		if !strings.EqualFold(e.Key, key) {
+			continue
+		}
+		line := lines[e.Line-1]
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		comment := ""
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			comment = " " + line[i:]
+		}
+		lines[e.Line-1] = fmt.Sprintf("%s%s = %s%s", indent, e.Key, value, comment)
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+
+	at := cfg.Interface.Line
+	lines = append(lines[:at], append([]string{fmt.Sprintf("%s = %s", key, value)}, lines[at:]...)...)
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// SetPeer returns data with the [Peer] section for publicKey replaced by
+// one holding entries, or with such a section appended if there is none.
+// Comments inside a replaced section are lost; every other line is kept.
+func SetPeer(data []byte, publicKey string, entries []Entry) ([]byte, error) {
+	section := []string{"[Peer]", "PublicKey = " + publicKey}
+	for _, e := range entries {
+		section = append(section, fmt.Sprintf("%s = %s", e.Key, e.Value))
+	}
+
+	lines, start, end, err := peerSpan(data, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		text := strings.TrimRight(string(data), "\n")
+		return []byte(text + "\n\n" + strings.Join(section, "\n") + "\n"), nil
+	}
+	lines = append(lines[:start], append(section, lines[end:]...)...)
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// RemovePeer returns data without the [Peer] section for publicKey
+func RemovePeer(data []byte, publicKey string) ([]byte, error) {
+	lines, start, end, err := peerSpan(data, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no peer %s", publicKey)
+	}
+	// Take the comments describing the peer and the blank line before
+	// them along with it
+	for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+		start--
+	}
+	if start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+		start--
+	}
+	lines = append(lines[:start], lines[end:]...)
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// peerSpan finds the lines of the [Peer] section for publicKey, without
+// the blank lines and comments that follow it, which belong to whatever
+// comes next. start is -1 if there is no such peer.
+func peerSpan(data []byte, publicKey string) (lines []string, start, end int, err error) {
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	lines = strings.Split(string(data), "\n")
+	start = -1
+	for i, p := range cfg.Peers {
+		if p.Get("PublicKey") != publicKey {
+			continue
+		}
+		start, end = p.Line-1, len(lines)
+		if i+1 < len(cfg.Peers) {
+			end = cfg.Peers[i+1].Line - 1
+		} else if cfg.Interface != nil && cfg.Interface.Line > p.Line {
+			end = cfg.Interface.Line - 1
+		}
+		for end > start+1 && stripComment(lines[end-1]) == "" {
+			end--
+		}
+		break
+	}
+	return lines, start, end, nil
+}
+
+// Validate checks the keys and values the way `wg-quick` and `wg setconf`
+// would, so mistakes are caught before they reach the kernel.
+func (c *Config) Validate() error {
+	var errs ConfigErrors
+	if c.Interface == nil {
+		errs = append(errs, ConfigError{0, "missing [Interface] section"})
+	} else {
+		errs = append(errs, validateSection(c.Interface, interfaceKeys)...)
+		if c.Interface.Get("PrivateKey") == "" {
+			errs = append(errs, ConfigError{c.Interface.Line, "[Interface] has no PrivateKey"})
+		}
+	}
+
+	seen := make(map[string]int)
+	for _, p := range c.Peers {
+		errs = append(errs, validateSection(p, peerKeys)...)
+		pk := p.Get("PublicKey")
+		if pk == "" {
+			errs = append(errs, ConfigError{p.Line, "[Peer] has no PublicKey"})
+			continue
+		}
+		if prev, ok := seen[pk]; ok {
+			errs = append(errs, ConfigError{p.Line, fmt.Sprintf("duplicate peer, already defined on line %d", prev)})
+		}
+		seen[pk] = p.Line
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type valueCheck func(string) error
+
+var interfaceKeys = map[string]valueCheck{
+	"privatekey": checkKey,
+	"listenport": checkPort,
+	"fwmark":     checkFwMark,
+	"address":    checkPrefixList,
+	"dns":        checkNothing,
+	"mtu":        checkUint(65535),
+	"table":      checkNothing,
+	"preup":      checkNothing,
+	"postup":     checkNothing,
+	"predown":    checkNothing,
+	"postdown":   checkNothing,
+	"saveconfig": checkBool,
+}
+
+var peerKeys = map[string]valueCheck{
+	"publickey":           checkKey,
+	"presharedkey":        checkKey,
+	"allowedips":          checkPrefixList,
+	"endpoint":            checkEndpoint,
+	"persistentkeepalive": checkKeepalive,
+}
+
+func validateSection(s *Section, keys map[string]valueCheck) []ConfigError {
+	var errs []ConfigError
+	for _, e := range s.Entries {
+		check, ok := keys[strings.ToLower(e.Key)]
+		if !ok {
+			errs = append(errs, ConfigError{e.Line, fmt.Sprintf("unknown key %q in [%s]", e.Key, s.Name)})
+			continue
+		}
+		if err := check(e.Value); err != nil {
+			errs = append(errs, ConfigError{e.Line, fmt.Sprintf("%s: %v", e.Key, err)})
+		}
+	}
+	return errs
+}
+
+func checkNothing(string) error { return nil }
+
+func checkKey(v string) error {
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(b) != 32 {
+		return fmt.Errorf("not a valid base64 key")
+	}
+	return nil
+}
+
+func checkPort(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", v)
+	}
+	return nil
+}
+
+func checkUint(max int) valueCheck {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > max {
+			return fmt.Errorf("expected a number between 0 and %d", max)
+		}
+		return nil
+	}
+}
+
+func checkFwMark(v string) error {
+	if strings.EqualFold(v, "off") {
+		return nil
+	}
+	if _, err := strconv.ParseUint(v, 0, 32); err != nil {
+		return fmt.Errorf("invalid fwmark %q", v)
+	}
+	return nil
+}
+
+func checkKeepalive(v string) error {
+	if strings.EqualFold(v, "off") {
+		return nil
+	}
+	return checkUint(65535)(v)
+}
+
+func checkBool(v string) error {
+	switch strings.ToLower(v) {
+	case "true", "false":
+		return nil
+	}
+	return fmt.Errorf("expected true or false")
+}
+
+func checkPrefixList(v string) error {
+	for _, part := range splitList(v) {
+		if _, err := ParsePrefix(part); err != nil {
+			return fmt.Errorf("invalid address %q", part)
+		}
+	}
+	return nil
+}
+
+func checkEndpoint(v string) error {
+	host, port, err := net.SplitHostPort(v)
+	if err != nil || host == "" {
+		return fmt.Errorf("expected host:port")
+	}
+	return checkPort(port)
+}
+
+// ParsePrefix accepts both CIDR notation and bare addresses, which
+// wg-quick treats as single-host prefixes.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,126 @@
+package wg
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrorKind is a reason a command fails that we know how to explain
+type ErrorKind string
+
+const (
+	ErrPermission   ErrorKind = "permission"
+	ErrMissingTool  ErrorKind = "missing-tool"
+	ErrNoModule     ErrorKind = "no-module"
+	ErrNoInterface  ErrorKind = "no-interface"
+	ErrConfig       ErrorKind = "config"
+	ErrAddressInUse ErrorKind = "address-in-use"
+)
+
+// Error is a command failure whose cause was recognised. Find it with
+// errors.As; the message stays that of the command.
+type Error struct {
+	Kind ErrorKind `json:"kind"`
+	// Tool is the command that failed, e.g. wg-quick
+	Tool string `json:"tool"`
+	// Output is the line of output the cause was recognised by
+	Output string `json:"output,omitempty"`
+	Err    error  `json:"-"`
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the *Error in err's chain, or nil if the cause of err is
+// not known
+func Cause(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
+
+// Explain says what the failure means
+func (e *Error) Explain() string {
+	switch e.Kind {
+	case ErrPermission:
+		return "WireGuard can only be managed by root"
+	case ErrMissingTool:
+		return e.Tool + " is not installed"
+	case ErrNoModule:
+		return "the kernel has no WireGuard support loaded"
+	case ErrNoInterface:
+		return "the interface does not exist, or has no config in " + ConfigDir
+	case ErrConfig:
+		return "the config file has a syntax error"
+	case ErrAddressInUse:
+		return "the ListenPort is already taken by another interface or program"
+	}
+	return ""
+}
+
+// Fix suggests what to do about it
+func (e *Error) Fix() string {
+	switch e.Kind {
+	case ErrPermission:
+		return "run with sudo or -sudo-helper; on remote hosts log in as root or allow sudo without a password"
+	case ErrMissingTool:
+		return "install wireguard-tools (apt install wireguard-tools, pacman -S wireguard-tools)"
+	case ErrNoModule:
+		return "run modprobe wireguard, or install wireguard-dkms on kernels older than 5.6"
+	case ErrNoInterface:
+		return fmt.Sprintf("check the name, or create %s", ConfigPath("<name>"))
+	case ErrConfig:
+		return "fix the line shown with F4, or restore an earlier version with F7"
+	case ErrAddressInUse:
+		return "choose another ListenPort, or stop what listens on it (ss -ulpn)"
+	}
+	return ""
+}
+
+// errorPatterns recognise causes by what wg, wg-quick, ip, sudo and the
+// shell print. Earlier entries win.
+var errorPatterns = []struct {
+	kind ErrorKind
+	text string
+}{
+	{ErrMissingTool, "command not found"},
+	{ErrMissingTool, ": not found"},
+	{ErrPermission, "must be run as root"},
+	{ErrPermission, "Operation not permitted"},
+	{ErrPermission, "Permission denied"},
+	{ErrPermission, "a password is required"},
+	{ErrNoModule, "Unknown device type"},
+	{ErrNoModule, "Operation not supported"},
+	{ErrNoModule, "Protocol not supported"},
+	{ErrNoInterface, "No such device"},
+	{ErrNoInterface, "is not a WireGuard interface"},
+	{ErrNoInterface, "does not exist"},
+	{ErrConfig, "Line unrecognized"},
+	{ErrConfig, "Configuration parsing error"},
+	{ErrAddressInUse, "Address already in use"},
+}
+
+// classify turns err into an *Error if stderr, or err itself, tells why
+// tool failed. Anything else comes back unchanged.
+func classify(tool string, err error, stderr string) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return &Error{Kind: ErrMissingTool, Tool: tool, Err: err}
+	}
+	for _, line := range strings.Split(stderr, "\n") {
+		for _, p := range errorPatterns {
+			if strings.Contains(line, p.text) {
+				return &Error{Kind: p.kind, Tool: tool, Output: strings.TrimSpace(line), Err: err}
+			}
+		}
+	}
+	return err
+}
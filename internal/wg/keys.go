@@ -0,0 +1,36 @@
+package wg
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// GenerateKeyPair creates a new Curve25519 key pair, the same as
+// `wg genkey | tee private | wg pubkey`, without shelling out
+func GenerateKeyPair() (private, public string, err error) {
+	var k [32]byte
+	if _, err := rand.Read(k[:]); err != nil {
+		return "", "", err
+	}
+	// Clamp like wg genkey so the stored key is canonical
+	k[0] &= 248
+	k[31] = (k[31] & 127) | 64
+	private = base64.StdEncoding.EncodeToString(k[:])
+	public, err = PublicKey(private)
+	return private, public, err
+}
+
+// PublicKey derives the public key of a base64 private key
+func PublicKey(private string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(private)
+	if err != nil || len(raw) != 32 {
+		return "", fmt.Errorf("invalid private key")
+	}
+	priv, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(priv.PublicKey().Bytes()), nil
+}
@@ -3,29 +3,111 @@ package wg
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
+	"io"
+	"io/fs"
+	"net/netip"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // LinuxClient implements Client using the `wg` command line tool
-type LinuxClient struct{}
+type LinuxClient struct {
+	// Runner runs wg, wg-quick and the odd shell command
+	Runner Runner
+	// Timeout bounds every command but wg-quick up and down, which get
+	// ToggleTimeout as they run hooks and resolve endpoints. Zero means
+	// the defaults.
+	Timeout       time.Duration
+	ToggleTimeout time.Duration
+	// ConfigGlobs are where configs are looked for, first match of a
+	// name winning. Defaults to /etc/wireguard/*.conf.
+	ConfigGlobs []string
+
+	mu sync.Mutex
+	// files maps interfaces to the configs found by the last listing
+	files map[string]string
+	// owners holds the owners last found for sockets by inode, as
+	// finding them means listing the fds of every process
+	owners map[uint64]UDPSocket
+}
+
+// Default command timeouts
+const (
+	DefaultTimeout       = 15 * time.Second
+	DefaultToggleTimeout = 2 * time.Minute
+)
 
 func NewLinuxClient() *LinuxClient {
-	return &LinuxClient{}
+	return &LinuxClient{Runner: LocalRunner{}}
 }
 
-func (c *LinuxClient) GetInterfaces() ([]Interface, error) {
-	// 1. Get active interfaces from wg show
-	output, err := runWgDump()
-	if err != nil {
-		// If wg fails (e.g. no permission), we might still want to list config files?
-		// For now, return error if we can't run wg (likely need sudo)
-		return nil, err
+// NewSSHClient manages the interfaces of another machine over SSH
+func NewSSHClient(host string) *LinuxClient {
+	return &LinuxClient{Runner: NewSSHRunner(host)}
+}
+
+// run runs a command with a deadline, and says so when the deadline or a
+// cancellation is what stopped it. Failures with a known cause come back
+// as an *Error.
+func (c *LinuxClient) run(ctx context.Context, timeout time.Duration, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var errOut bytes.Buffer
+	var w io.Writer = &errOut
+	if stderr != nil {
+		w = io.MultiWriter(&errOut, stderr)
+	}
+	err := c.Runner.Run(ctx, stdin, stdout, w, name, args...)
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("%s timed out after %s", name, timeout)
+	case ctx.Err() == context.Canceled:
+		return fmt.Errorf("%s cancelled", name)
 	}
+	return classify(name, err, errOut.String())
+}
+
+func (c *LinuxClient) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultTimeout
+}
+
+func (c *LinuxClient) toggleTimeout() time.Duration {
+	if c.ToggleTimeout > 0 {
+		return c.ToggleTimeout
+	}
+	return DefaultToggleTimeout
+}
+
+// output runs a command and returns what it printed on stdout
+func (c *LinuxClient) output(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	err := c.run(ctx, c.timeout(), stdin, &out, nil, name, args...)
+	return out.Bytes(), err
+}
+
+// combinedOutput runs a command and returns stdout and stderr together
+func (c *LinuxClient) combinedOutput(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	err := c.run(ctx, c.timeout(), stdin, &out, &out, name, args...)
+	return out.Bytes(), err
+}
+
+func (c *LinuxClient) GetInterfaces(ctx context.Context) ([]Interface, error) {
+	// 1. Get active interfaces from wg show. If wg fails (e.g. no
+	// permission) the configs are still listed, along with the error.
+	output, wgErr := c.runWgDump(ctx)
 	activeInterfaces := parseInterfaces(output)
 
 	// Mark all parsed interfaces as UP (they came from `wg show`, so they are running)
@@ -43,26 +125,86 @@ func (c *LinuxClient) GetInterfaces() ([]Interface, error) {
 		seen[iface.Name] = true
 	}
 
-	// 2. Scan /etc/wireguard/*.conf for all available configs
-	configFiles, _ := filepath.Glob("/etc/wireguard/*.conf")
+	// 2. Scan the config globs for all available configs
+	files, stamps := c.listConfigs(ctx)
+	for i := range allInterfaces {
+		name := allInterfaces[i].Name
+		allInterfaces[i].ConfigFile, allInterfaces[i].ConfigStamp = files[name], stamps[name]
+	}
 
 	// Add inactive interfaces from config files
-	for _, file := range configFiles {
-		name := strings.TrimSuffix(filepath.Base(file), ".conf")
+	var inactive []string
+	for name := range files {
 		if !seen[name] {
-			allInterfaces = append(allInterfaces, Interface{
-				Name:   name,
-				Status: InterfaceDown,
-			})
-			seen[name] = true
+			inactive = append(inactive, name)
+		}
+	}
+	sort.Strings(inactive)
+	// Without wg there is no telling which of them are running
+	status := InterfaceDown
+	if wgErr != nil {
+		status = InterfaceUnknown
+	}
+	for _, name := range inactive {
+		allInterfaces = append(allInterfaces, Interface{
+			Name:        name,
+			Status:      status,
+			ConfigFile:  files[name],
+			ConfigStamp: stamps[name],
+		})
+	}
+
+	return allInterfaces, wgErr
+}
+
+// listScript prints the files matching each glob given, which the shell
+// expands on the machine the configs are on, each after its inode, size
+// and modification time
+const listScript = `for g in "$@"; do for f in $g; do [ -f "$f" ] && echo "$(stat -c %i.%s.%Y -- "$f" 2>/dev/null) $f"; done; done`
+
+// listConfigs finds the configs by interface name, and their stamps, and
+// remembers where they are for the calls that need the file
+func (c *LinuxClient) listConfigs(ctx context.Context) (files, stamps map[string]string) {
+	globs := c.ConfigGlobs
+	if len(globs) == 0 {
+		globs = []string{filepath.Join(ConfigDir, "*.conf")}
+	}
+	listing, _ := c.output(ctx, nil, "sh", append([]string{"-c", listScript, "sh"}, globs...)...)
+	files, stamps = make(map[string]string), make(map[string]string)
+	for _, line := range strings.Split(string(listing), "\n") {
+		stamp, file, _ := strings.Cut(line, " ")
+		name, ok := strings.CutSuffix(filepath.Base(file), ".conf")
+		if ok && ValidInterfaceName(name) && files[name] == "" {
+			files[name], stamps[name] = file, stamp
 		}
 	}
+	c.mu.Lock()
+	c.files = files
+	c.mu.Unlock()
+	return files, stamps
+}
 
-	return allInterfaces, nil
+// configFile is where the config of an interface is, or would be
+func (c *LinuxClient) configFile(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if file := c.files[name]; file != "" {
+		return file
+	}
+	return ConfigPath(name)
+}
+
+// quickArg is how wg-quick is told about an interface: by name when its
+// config is where wg-quick looks, by path otherwise
+func (c *LinuxClient) quickArg(name string) string {
+	if file := c.configFile(name); file != ConfigPath(name) {
+		return file
+	}
+	return name
 }
 
-func (c *LinuxClient) GetPeers(interfaceName string) ([]Peer, error) {
-	output, err := runWgDump()
+func (c *LinuxClient) GetPeers(ctx context.Context, interfaceName string) ([]Peer, error) {
+	output, err := c.runWgDump(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +213,7 @@ func (c *LinuxClient) GetPeers(interfaceName string) ([]Peer, error) {
 	return parsePeers(output, interfaceName), nil
 }
 
-func (c *LinuxClient) ToggleInterface(name string, up bool) error {
+func (c *LinuxClient) ToggleInterface(ctx context.Context, name string, up bool, out io.Writer) error {
 	var action string
 	if up {
 		action = "up"
@@ -79,22 +221,202 @@ func (c *LinuxClient) ToggleInterface(name string, up bool) error {
 		action = "down"
 	}
 
-	cmd := exec.Command("wg-quick", action, name)
-	output, err := cmd.CombinedOutput()
+	var output bytes.Buffer
+	var w io.Writer = &output
+	if out != nil {
+		w = io.MultiWriter(&output, out)
+	}
+	if err := c.run(ctx, c.toggleTimeout(), nil, w, w, "wg-quick", action, c.quickArg(name)); err != nil {
+		// The full output went to out; the last line usually says why
+		return fmt.Errorf("wg-quick failed: %w, output: %s", err, lastLine(output.String()))
+	}
+	return nil
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+func (c *LinuxClient) ReadConfig(ctx context.Context, name string) ([]byte, error) {
+	if !ValidInterfaceName(name) {
+		return nil, fmt.Errorf("invalid interface name %q", name)
+	}
+	file := c.configFile(name)
+	var data, errOut bytes.Buffer
+	if err := c.run(ctx, c.timeout(), nil, &data, &errOut, "cat", file); err != nil {
+		// Keep os.IsNotExist working, whichever machine the file is on
+		if strings.Contains(errOut.String(), "No such file") {
+			return nil, &fs.PathError{Op: "open", Path: file, Err: fs.ErrNotExist}
+		}
+		return nil, fmt.Errorf("failed to read %s: %w, output: %s", file, err, lastLine(errOut.String()))
+	}
+	return data.Bytes(), nil
+}
+
+// writeScript writes stdin to the config "$2" in "$1" through a temp file
+// and a rename, so a crash never leaves a half-written config
+const writeScript = `umask 077; t=$(mktemp "$1/.$2.conf.XXXXXX") || exit 1; cat > "$t" && mv "$t" "$1/$2.conf" || { rm -f "$t"; exit 1; }`
+
+func (c *LinuxClient) WriteConfig(ctx context.Context, name string, data []byte) error {
+	if !ValidInterfaceName(name) {
+		return fmt.Errorf("invalid interface name %q", name)
+	}
+	output, err := c.combinedOutput(ctx, bytes.NewReader(data), "sh", "-c", writeScript, "sh", filepath.Dir(c.configFile(name)), name)
 	if err != nil {
-		return fmt.Errorf("wg-quick failed: %v, output: %s", err, string(output))
+		return fmt.Errorf("failed to write config: %w, output: %s", err, lastLine(string(output)))
 	}
 	return nil
 }
 
-// Helper to run `wg show all dump`
-func runWgDump() (string, error) {
-	cmd := exec.Command("wg", "show", "all", "dump")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
+func (c *LinuxClient) SyncConfig(ctx context.Context, name string) error {
+	if !ValidInterfaceName(name) {
+		return fmt.Errorf("invalid interface name %q", name)
+	}
+	// wg syncconf only understands the wg(8) subset, so let wg-quick strip
+	// Address, DNS, PostUp and friends first.
+	stripped, err := c.output(ctx, nil, "wg-quick", "strip", c.quickArg(name))
+	if err != nil {
+		return fmt.Errorf("wg-quick strip failed: %w", err)
+	}
+	output, err := c.combinedOutput(ctx, bytes.NewReader(stripped), "wg", "syncconf", name, "/dev/stdin")
 	if err != nil {
-		return "", fmt.Errorf("failed to run wg show: %v", err)
+		return fmt.Errorf("wg syncconf failed: %w, output: %s", err, string(output))
+	}
+	return nil
+}
+
+func (c *LinuxClient) SetPrivateKey(ctx context.Context, name string, privateKey string) error {
+	if !ValidInterfaceName(name) {
+		return fmt.Errorf("invalid interface name %q", name)
+	}
+	// Pass the key on stdin so it never shows up in the process list
+	output, err := c.combinedOutput(ctx, strings.NewReader(privateKey+"\n"), "wg", "set", name, "private-key", "/dev/stdin")
+	if err != nil {
+		return fmt.Errorf("wg set failed: %w, output: %s", err, string(output))
+	}
+	return nil
+}
+
+func (c *LinuxClient) RouteDevice(ctx context.Context, dst netip.Addr) (string, error) {
+	out, err := c.output(ctx, nil, "ip", "route", "get", dst.String())
+	if err != nil {
+		return "", fmt.Errorf("ip route get failed: %v", err)
+	}
+	// e.g. "1.1.1.1 dev wg0 table 51820 src 10.9.0.2 uid 0"
+	fields := strings.Fields(string(out))
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "dev" {
+			return fields[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("no route to %s", dst)
+}
+
+// SessionClient is where the host sees us come from: over SSH the client
+// address of our connection, and locally that of the session we run in
+func (c *LinuxClient) SessionClient(ctx context.Context) (netip.Addr, bool) {
+	r, ok := c.Runner.(*SSHRunner)
+	if !ok {
+		return sessionClient()
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	return r.SessionClient(ctx)
+}
+
+// timerScript starts the safety timer in a session of its own, which
+// keeps it alive when our SSH session, and with it this process, goes
+// away. Its pid doubles as the process group to kill.
+const timerScript = `setsid sh -c 'sleep "$1" && exec wg-quick up "$2"' sh "$1" "$2" </dev/null >/dev/null 2>&1 & echo $!`
+
+func (c *LinuxClient) ArmSafetyTimer(ctx context.Context, name string, after time.Duration) (func() error, error) {
+	if !ValidInterfaceName(name) {
+		return nil, fmt.Errorf("invalid interface name %q", name)
+	}
+	secs := int(after.Round(time.Second) / time.Second)
+	out, err := c.output(ctx, nil, "sh", "-c", timerScript, "sh", strconv.Itoa(secs), c.quickArg(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start safety timer: %v", err)
+	}
+	pgid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start safety timer: unexpected output %q", out)
+	}
+	return func() error {
+		if output, err := c.combinedOutput(context.Background(), nil, "sh", "-c", `kill -TERM -"$1"`, "sh", strconv.Itoa(pgid)); err != nil {
+			return fmt.Errorf("failed to stop safety timer: %v, output: %s", err, lastLine(string(output)))
+		}
+		return nil
+	}, nil
+}
+
+func (c *LinuxClient) UDPSockets(ctx context.Context, ports []int) ([]UDPSocket, error) {
+	// udp6 is missing when IPv6 is disabled; what cat did read still counts
+	table, err := c.output(ctx, nil, "cat", "/proc/net/udp", "/proc/net/udp6")
+	if err != nil && len(table) == 0 {
+		return nil, fmt.Errorf("failed to read UDP sockets: %v", err)
+	}
+	sockets := ParseProcNetUDP(table)
+
+	// A socket keeps its inode while it is open, so the owners only need
+	// looking up again when a socket on a wanted port is new
+	c.mu.Lock()
+	cached := c.owners
+	c.mu.Unlock()
+	fresh := true
+	for _, s := range sockets {
+		if _, ok := cached[s.Inode]; s.Inode != 0 && slices.Contains(ports, s.Port) && !ok {
+			fresh = false
+		}
+	}
+	if fresh {
+		for i, s := range sockets {
+			if owner, ok := cached[s.Inode]; ok && s.Inode != 0 {
+				sockets[i].PID, sockets[i].Command = owner.PID, owner.Command
+			}
+		}
+		return sockets, nil
+	}
+
+	// ls stops at processes that exit meanwhile, but lists the rest
+	listing, _ := c.output(ctx, nil, "sh", "-c", "ls -l /proc/[0-9]*/fd 2>/dev/null")
+	owners := ParseSocketOwners(listing)
+	found := make(map[uint64]UDPSocket)
+	for i, s := range sockets {
+		if s.Inode == 0 || !slices.Contains(ports, s.Port) {
+			continue
+		}
+		// Sockets with no owner found are remembered too, or they
+		// would be looked for again on every call
+		found[s.Inode] = UDPSocket{}
+		pid := owners[s.Inode]
+		if pid == 0 {
+			continue
+		}
+		comm, err := c.output(ctx, nil, "cat", fmt.Sprintf("/proc/%d/comm", pid))
+		if err == nil {
+			sockets[i].PID, sockets[i].Command = pid, strings.TrimSpace(string(comm))
+			found[s.Inode] = sockets[i]
+		}
+	}
+	// A listing that failed outright says nothing about the owners
+	if len(listing) > 0 {
+		c.mu.Lock()
+		c.owners = found
+		c.mu.Unlock()
+	}
+	return sockets, nil
+}
+
+// Helper to run `wg show all dump`
+func (c *LinuxClient) runWgDump(ctx context.Context) (string, error) {
+	var out, errOut bytes.Buffer
+	if err := c.run(ctx, c.timeout(), nil, &out, &errOut, "wg", "show", "all", "dump"); err != nil {
+		return "", fmt.Errorf("failed to run wg show: %w, output: %s", err, lastLine(errOut.String()))
 	}
 	return out.String(), nil
 }
@@ -118,14 +440,15 @@ func parseInterfaces(output string) []Interface {
 				continue
 			}
 
+			// name, private key, public key, port, fwmark as "off" or hex
 			port, _ := strconv.Atoi(parts[3])
-			fwMark, _ := strconv.Atoi(parts[4])
+			fwMark, _ := strconv.ParseInt(parts[4], 0, 64)
 
 			interfaces = append(interfaces, Interface{
 				Name:         name,
-				PublicKey:    parts[1],
+				PublicKey:    parts[2],
 				ListenPort:   port,
-				FirewallMark: fwMark,
+				FirewallMark: int(fwMark),
 			})
 			seen[name] = true
 		} else if len(parts) > 5 {
@@ -0,0 +1,201 @@
+package wg
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hang is a command that runs until it is stopped
+func hang(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestLinuxClientTimeout(t *testing.T) {
+	c := &LinuxClient{Runner: RunnerFunc(hang), Timeout: 10 * time.Millisecond}
+	_, err := c.GetPeers(context.Background(), "wg0")
+	if err == nil || !strings.Contains(err.Error(), "wg timed out after 10ms") {
+		t.Errorf("got %v, want a timeout", err)
+	}
+}
+
+func TestLinuxClientCancel(t *testing.T) {
+	c := &LinuxClient{Runner: RunnerFunc(hang)}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := c.ToggleInterface(ctx, "wg0", true, nil)
+	if err == nil || !strings.Contains(err.Error(), "wg-quick cancelled") {
+		t.Errorf("got %v, want a cancellation", err)
+	}
+}
+
+func TestLinuxClientDump(t *testing.T) {
+	dump := "wg0\tcHJpdg==\tcHViMA==\t51820\toff\n" +
+		"wg0\tcGVlcjE=\t(none)\t198.51.100.7:51820\t10.0.0.2/32,fd00::2/128\t1700000000\t100\t200\t25\n" +
+		"wg0\tcGVlcjI=\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff\n" +
+		"wg1\tcHJpdg==\tcHViMQ==\t51821\t0x1234\n"
+	c := &LinuxClient{Runner: RunnerFunc(func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+		switch name {
+		case "wg":
+			io.WriteString(stdout, dump)
+		case "sh":
+			io.WriteString(stdout, "131.180.1700000000 /etc/wireguard/wg0.conf\n132.95.1700000001 /etc/wireguard/wg2.conf\n133.1.1700000002 /etc/wireguard/not a name.conf\n")
+		}
+		return nil
+	})}
+
+	ifaces, err := c.GetInterfaces(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Interface{
+		{Name: "wg0", PublicKey: "cHViMA==", ListenPort: 51820, Status: InterfaceUp, ConfigFile: "/etc/wireguard/wg0.conf", ConfigStamp: "131.180.1700000000"},
+		{Name: "wg1", PublicKey: "cHViMQ==", ListenPort: 51821, FirewallMark: 0x1234, Status: InterfaceUp},
+		{Name: "wg2", Status: InterfaceDown, ConfigFile: "/etc/wireguard/wg2.conf", ConfigStamp: "132.95.1700000001"},
+	}
+	if len(ifaces) != len(want) {
+		t.Fatalf("got %+v, want %+v", ifaces, want)
+	}
+	for i := range want {
+		if ifaces[i] != want[i] {
+			t.Errorf("interface %d is %+v, want %+v", i, ifaces[i], want[i])
+		}
+	}
+
+	peers, err := c.GetPeers(context.Background(), "wg0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("got %d peers, want 2", len(peers))
+	}
+	p := peers[0]
+	if p.PublicKey != "cGVlcjE=" || p.Endpoint != "198.51.100.7:51820" || len(p.AllowedIPs) != 2 ||
+		p.TransferRx != 100 || p.TransferTx != 200 || p.PersistentKeepalive != 25 ||
+		!p.LatestHandshake.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("first peer is %+v", p)
+	}
+	if !peers[1].LatestHandshake.IsZero() || peers[1].PersistentKeepalive != 0 {
+		t.Errorf("second peer is %+v, want no handshake or keepalive", peers[1])
+	}
+}
+
+func TestLinuxClientReadConfigNotFound(t *testing.T) {
+	c := &LinuxClient{Runner: RunnerFunc(func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+		io.WriteString(stderr, "cat: "+args[0]+": No such file or directory\n")
+		return exitStatus(1)
+	})}
+	_, err := c.ReadConfig(context.Background(), "wg0")
+	if !os.IsNotExist(err) {
+		t.Errorf("got %v, want a not-exist error", err)
+	}
+	if _, err := c.ReadConfig(context.Background(), "../shadow"); err == nil || os.IsNotExist(err) {
+		t.Errorf("got %v for a bad name, want it refused", err)
+	}
+}
+
+func TestLinuxClientWriteConfig(t *testing.T) {
+	var gotArgs []string
+	var written string
+	fail := false
+	c := &LinuxClient{Runner: RunnerFunc(func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+		gotArgs = append([]string{name}, args...)
+		data, _ := io.ReadAll(stdin)
+		written = string(data)
+		if fail {
+			io.WriteString(stderr, "mktemp: failed to create file: Read-only file system\n")
+			return exitStatus(1)
+		}
+		return nil
+	})}
+
+	data := "[Interface]\nPrivateKey = x\n"
+	if err := c.WriteConfig(context.Background(), "wg0", []byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if written != data {
+		t.Errorf("wrote %q, want %q", written, data)
+	}
+	// The directory and name go to the script as arguments, never
+	// pasted into it
+	want := []string{"sh", "-c", writeScript, "sh", "/etc/wireguard", "wg0"}
+	if strings.Join(gotArgs, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("ran %q, want %q", gotArgs, want)
+	}
+
+	fail = true
+	err := c.WriteConfig(context.Background(), "wg0", []byte(data))
+	if err == nil || !strings.Contains(err.Error(), "Read-only file system") {
+		t.Errorf("got %v, want the mktemp error", err)
+	}
+
+	gotArgs = nil
+	if err := c.WriteConfig(context.Background(), "wg0; reboot", []byte(data)); err == nil || gotArgs != nil {
+		t.Errorf("got %v running %q for a bad name, want it refused", err, gotArgs)
+	}
+}
+
+func TestLinuxClientUnknownWhenWgFails(t *testing.T) {
+	c := &LinuxClient{Runner: RunnerFunc(func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+		if name == "wg" {
+			io.WriteString(stderr, "Unable to access interface: Operation not permitted\n")
+			return exitStatus(1)
+		}
+		io.WriteString(stdout, "131.180.1700000000 /etc/wireguard/wg0.conf\n")
+		return nil
+	})}
+	ifaces, err := c.GetInterfaces(context.Background())
+	if err == nil {
+		t.Error("got no error")
+	}
+	if len(ifaces) != 1 || ifaces[0].Status != InterfaceUnknown {
+		t.Errorf("got %+v, want wg0 in an unknown state", ifaces)
+	}
+}
+
+func TestLinuxClientUDPSocketsCachesOwners(t *testing.T) {
+	// Port 51820 (0xCA6C) held by inode 4242, then by a new socket 4343
+	udp := func(inode string) string {
+		return "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+			"  12: 00000000:CA6C 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 " + inode + " 2 0000000000000000 0\n"
+	}
+	table := udp("4242")
+	lookups := 0
+	c := &LinuxClient{Runner: RunnerFunc(func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+		switch {
+		case name == "cat" && args[0] == "/proc/net/udp":
+			io.WriteString(stdout, table)
+		case name == "cat":
+			io.WriteString(stdout, "dnsmasq\n")
+		case name == "sh":
+			lookups++
+			io.WriteString(stdout, "/proc/812/fd:\nlrwx------ 1 root root 64 Jan  1 00:00 3 -> socket:[4242]\nlrwx------ 1 root root 64 Jan  1 00:00 4 -> socket:[4343]\n")
+		}
+		return nil
+	})}
+
+	for range 3 {
+		sockets, err := c.UDPSockets(context.Background(), []int{51820})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sockets) != 1 || sockets[0].PID != 812 || sockets[0].Command != "dnsmasq" {
+			t.Fatalf("got %+v, want dnsmasq holding the port", sockets)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("looked up owners %d times for an unchanged table, want once", lookups)
+	}
+
+	table = udp("4343")
+	if _, err := c.UDPSockets(context.Background(), []int{51820}); err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 2 {
+		t.Errorf("looked up owners %d times after the socket changed, want twice", lookups)
+	}
+}
@@ -0,0 +1,186 @@
+package wg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteInspector is implemented by clients that can tell which interface
+// traffic to an address leaves through
+type RouteInspector interface {
+	// RouteDevice returns the name of the interface used to reach dst
+	RouteDevice(ctx context.Context, dst netip.Addr) (string, error)
+}
+
+// SafetyTimer is implemented by clients that can bring an interface back up
+// after a delay, from a process that outlives the current session
+type SafetyTimer interface {
+	// ArmSafetyTimer schedules `wg-quick up name`. The returned function
+	// disarms it. ctx only bounds the arming; the timer outlives it.
+	ArmSafetyTimer(ctx context.Context, name string, after time.Duration) (cancel func() error, err error)
+}
+
+// SessionLocator is implemented by clients that manage a host over a
+// connection of their own, whose loss the lockout check has to weigh
+// instead of that of the session we run in
+type SessionLocator interface {
+	// SessionClient returns the address the host sees the connection
+	// come from
+	SessionClient(ctx context.Context) (netip.Addr, bool)
+}
+
+// defaultRouteProbes stand in for "the internet" when asking which
+// interface the default route uses; wg-quick installs its default route
+// in a separate table, so `ip route show default` would miss it.
+var defaultRouteProbes = []struct {
+	family string
+	addr   netip.Addr
+}{
+	{"IPv4", netip.MustParseAddr("1.1.1.1")},
+	{"IPv6", netip.MustParseAddr("2606:4700:4700::1111")},
+}
+
+// LockoutRisks returns the reasons why taking the interface down could cut
+// off whoever is running this program: the SSH session, see
+// sessionClient, or for a remote host the connection to it, or the
+// default route being reached through it.
+func LockoutRisks(ctx context.Context, ri RouteInspector, name string) []string {
+	var risks []string
+	client, ok := sessionClient()
+	if c, isClient := ri.(Client); isClient {
+		if sl, found := As[SessionLocator](c); found {
+			client, ok = sl.SessionClient(ctx)
+		}
+	}
+	if ok {
+		if dev, err := ri.RouteDevice(ctx, client); err == nil && dev == name {
+			risks = append(risks, fmt.Sprintf("your SSH session from %s is routed through %s", client, name))
+		}
+	}
+	for _, probe := range defaultRouteProbes {
+		if dev, err := ri.RouteDevice(ctx, probe.addr); err == nil && dev == name {
+			risks = append(risks, fmt.Sprintf("the %s default route goes through %s", probe.family, name))
+		}
+	}
+	return risks
+}
+
+// sessionClient finds the address the SSH session we run in comes from.
+// sudo resets the environment, dropping SSH_CONNECTION, so failing that
+// our ancestors are searched: the shell sudo was started from still has
+// the variable, and otherwise the peer of the sshd above us is used.
+func sessionClient() (netip.Addr, bool) {
+	if addr, ok := sshClient(os.Getenv("SSH_CONNECTION")); ok {
+		return addr, true
+	}
+	for pid := os.Getppid(); pid > 1; pid = parentPID(pid) {
+		env, _ := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+		for _, kv := range bytes.Split(env, []byte{0}) {
+			if conn, ok := bytes.CutPrefix(kv, []byte("SSH_CONNECTION=")); ok {
+				if addr, ok := sshClient(string(conn)); ok {
+					return addr, true
+				}
+			}
+		}
+		comm, _ := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+		// OpenSSH 9.8 moved sessions into sshd-session
+		if c := strings.TrimSpace(string(comm)); c == "sshd" || c == "sshd-session" {
+			return sshdPeer(pid)
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// parentPID reads the parent of pid from /proc/pid/stat, whose fields
+// after the command in parentheses are the state and then the parent.
+// It returns 0 when pid is gone.
+func parentPID(pid int) int {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 2 {
+		return 0
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return ppid
+}
+
+// sshdPeer is the remote address of the established TCP connection held
+// by an sshd process
+func sshdPeer(pid int) (netip.Addr, bool) {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	fds, err := os.ReadDir(dir)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	inodes := make(map[string]bool)
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(dir, fd.Name()))
+		if inode, ok := strings.CutPrefix(target, "socket:["); err == nil && ok {
+			inodes[strings.TrimSuffix(inode, "]")] = true
+		}
+	}
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, _ := os.ReadFile(table)
+		if addr, ok := tcpPeer(data, inodes); ok {
+			return addr, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// tcpPeer finds the remote address of the first established connection
+// in a /proc/net/tcp or tcp6 table whose inode is one of inodes. See
+// ParseProcNetUDP for the layout.
+func tcpPeer(table []byte, inodes map[string]bool) (netip.Addr, bool) {
+	const established = "01"
+	scanner := bufio.NewScanner(bytes.NewReader(table))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != established || !inodes[fields[9]] {
+			continue
+		}
+		hexAddr, _, _ := strings.Cut(fields[2], ":")
+		b, err := hex.DecodeString(hexAddr)
+		if err != nil || (len(b) != 4 && len(b) != 16) {
+			continue
+		}
+		// Each 32-bit word is printed in host order, which is little
+		// endian on everything we run on
+		for i := 0; i < len(b); i += 4 {
+			b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+		}
+		addr, _ := netip.AddrFromSlice(b)
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// sshClient extracts the client address from SSH_CONNECTION, which reads
+// "client_ip client_port server_ip server_port"
+func sshClient(conn string) (netip.Addr, bool) {
+	fields := strings.Fields(conn)
+	if len(fields) != 4 {
+		return netip.Addr{}, false
+	}
+	addr, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
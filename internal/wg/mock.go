@@ -1,14 +1,29 @@
 package wg
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"math/rand"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 type MockClient struct {
 	Interfaces []Interface
 	Peers      map[string][]Peer
+	Configs    map[string][]byte
+	// ToggleDelay simulates how long wg-quick takes to bring an
+	// interface up or down
+	ToggleDelay time.Duration
+	// Sockets are bound by other programs, to try port conflicts with
+	Sockets []UDPSocket
+
+	// Operations run in the background alongside refreshes
+	mu sync.Mutex
 }
 
 func NewMockClient() *MockClient {
@@ -31,19 +46,78 @@ func NewMockClient() *MockClient {
 		{PublicKey: "PeEr3 (Ina...", Endpoint: "Unknown", AllowedIPs: []string{"192.168.2.4/32"}, LatestHandshake: time.Now().Add(-48 * time.Hour), TransferRx: 1024, TransferTx: 2048, PersistentKeepalive: 0},
 	}
 
+	configs := map[string][]byte{
+		"wg0": []byte(`[Interface]
+# Office gateway
+PrivateKey = zMCR7rbJhpQglJZOX/gWPMTaDFVBO0alGudpsQcia1Q=
+Address = 10.0.0.1/24
+ListenPort = 51820
+PostUp = iptables -A FORWARD -i %i -j ACCEPT
+PostDown = iptables -D FORWARD -i %i -j ACCEPT
+
+[Peer]
+PublicKey = CjfJ/kFuGpMCYKb01h8k0NF1dteJ7kCi2xJ34KwMguw=
+PresharedKey = MYnl1yEieHncUS5t8O9KXuQaFerZva9BhKy7nN6Y2UY=
+AllowedIPs = 10.0.0.2/32
+PersistentKeepalive = 25
+
+[Peer]
+PublicKey = Fskv7bkYtOd8/RWGBHpqMuIlKz0O+9a4rlQ298ecylg=
+AllowedIPs = 10.0.0.3/32
+PersistentKeepalive = 25
+
+[Peer]
+PublicKey = U/XFOuRV3KL4WfMh5n7R/8R/NLDY+5adeoyqXwRxrxM=
+AllowedIPs = 10.0.0.4/32
+`),
+		"wg1": []byte(`[Interface]
+PrivateKey = 9bcDIg7OalkZknHkNzz4DvZW8kTg3zZVdcWKSrrQ/7Y=
+Address = 192.168.2.1/24
+ListenPort = 51821
+
+[Peer]
+PublicKey = mToIajpYN8/TmQLML74N37FxQ5cuAOKxlE3NaX3QVig=
+AllowedIPs = 192.168.2.2/32
+Endpoint = 192.168.1.10:51820
+
+[Peer]
+PublicKey = sSKvPR4UgFKUpflwFPuxn2uhRiZztWEmfCmVUU0Qpys=
+AllowedIPs = 192.168.2.3/32
+Endpoint = 203.0.113.5:12345
+`),
+		"wg2": []byte(`[Interface]
+PrivateKey = IPCHykmfpF+TvqUmwwwgUfZHnTrwQfEEY+jbJJ+JsUA=
+Address = 10.9.0.2/32
+DNS = 10.9.0.1
+
+[Peer]
+PublicKey = CjfJ/kFuGpMCYKb01h8k0NF1dteJ7kCi2xJ34KwMguw=
+AllowedIPs = 0.0.0.0/0
+Endpoint = vpn.example.com:51820
+`),
+	}
+
 	return &MockClient{
-		Interfaces: ifaces,
-		Peers:      peers,
+		Interfaces:  ifaces,
+		Peers:       peers,
+		Configs:     configs,
+		ToggleDelay: 800 * time.Millisecond,
+		Sockets: []UDPSocket{
+			{Port: 53, Inode: 18231, PID: 704, Command: "dnsmasq"},
+			{Port: 5353, Inode: 19012, PID: 612, Command: "avahi-daemon"},
+		},
 	}
 }
 
-func (c *MockClient) GetInterfaces() ([]Interface, error) {
-	// Simulate delay?
-	// time.Sleep(100 * time.Millisecond)
-	return c.Interfaces, nil
+func (c *MockClient) GetInterfaces(ctx context.Context) ([]Interface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interface(nil), c.Interfaces...), nil
 }
 
-func (c *MockClient) GetPeers(interfaceName string) ([]Peer, error) {
+func (c *MockClient) GetPeers(ctx context.Context, interfaceName string) ([]Peer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if p, ok := c.Peers[interfaceName]; ok {
 		// Randomize some data for liveness
 		for i := range p {
@@ -53,12 +127,25 @@ func (c *MockClient) GetPeers(interfaceName string) ([]Peer, error) {
 				p[i].LatestHandshake = time.Now()
 			}
 		}
-		return p, nil
+		return append([]Peer(nil), p...), nil
 	}
 	return nil, nil
 }
 
-func (c *MockClient) ToggleInterface(name string, up bool) error {
+func (c *MockClient) ToggleInterface(ctx context.Context, name string, up bool, out io.Writer) error {
+	steps := c.wgQuickSteps(name, up)
+	for _, step := range steps {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wg-quick %s: %v", name, ctx.Err())
+		case <-time.After(c.ToggleDelay / time.Duration(len(steps))):
+		}
+		if out != nil {
+			fmt.Fprintf(out, "[#] %s\n", step)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	// Find interface and update status
 	for i, iface := range c.Interfaces {
 		if iface.Name == name {
@@ -72,3 +159,139 @@ func (c *MockClient) ToggleInterface(name string, up bool) error {
 	}
 	return fmt.Errorf("interface not found")
 }
+
+// wgQuickSteps are the commands wg-quick would print for a toggle
+func (c *MockClient) wgQuickSteps(name string, up bool) []string {
+	c.mu.Lock()
+	cfg, _ := ParseConfig(c.Configs[name])
+	c.mu.Unlock()
+
+	if cfg == nil || cfg.Interface == nil {
+		cfg = &Config{Interface: &Section{}}
+	}
+	hooks := func(key string) []string {
+		var out []string
+		for _, e := range cfg.Interface.Entries {
+			if strings.EqualFold(e.Key, key) {
+				out = append(out, strings.ReplaceAll(e.Value, "%i", name))
+			}
+		}
+		return out
+	}
+	if !up {
+		steps := hooks("PreDown")
+		steps = append(steps, "ip link delete dev "+name)
+		return append(steps, hooks("PostDown")...)
+	}
+
+	steps := hooks("PreUp")
+	steps = append(steps, "ip link add "+name+" type wireguard", "wg setconf "+name+" /dev/fd/63")
+	for _, addr := range cfg.Interface.List("Address") {
+		family := "-4"
+		if strings.Contains(addr, ":") {
+			family = "-6"
+		}
+		steps = append(steps, fmt.Sprintf("ip %s address add %s dev %s", family, addr, name))
+	}
+	steps = append(steps, "ip link set mtu 1420 up dev "+name)
+	return append(steps, hooks("PostUp")...)
+}
+
+// RouteDevice picks the up interface whose peers have the most specific
+// AllowedIPs for dst, like wg-quick's routes would
+func (c *MockClient) RouteDevice(ctx context.Context, dst netip.Addr) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dev, bits := "eth0", -1
+	for _, iface := range c.Interfaces {
+		if iface.Status != InterfaceUp {
+			continue
+		}
+		cfg, err := ParseConfig(c.Configs[iface.Name])
+		if err != nil {
+			continue
+		}
+		for _, peer := range cfg.Peers {
+			for _, s := range peer.List("AllowedIPs") {
+				if p, err := netip.ParsePrefix(s); err == nil && p.Contains(dst) && p.Bits() > bits {
+					dev, bits = iface.Name, p.Bits()
+				}
+			}
+		}
+	}
+	return dev, nil
+}
+
+func (c *MockClient) ArmSafetyTimer(ctx context.Context, name string, after time.Duration) (func() error, error) {
+	t := time.AfterFunc(after, func() { c.ToggleInterface(context.Background(), name, true, nil) })
+	return func() error {
+		if !t.Stop() {
+			return fmt.Errorf("safety timer already fired")
+		}
+		return nil
+	}, nil
+}
+
+// UDPSockets lists the sockets of the up interfaces, which belong to the
+// kernel, along with those of the pretend programs
+func (c *MockClient) UDPSockets(ctx context.Context, ports []int) ([]UDPSocket, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sockets := slices.Clone(c.Sockets)
+	for _, iface := range c.Interfaces {
+		if iface.Status == InterfaceUp && iface.ListenPort > 0 {
+			sockets = append(sockets, UDPSocket{Port: iface.ListenPort})
+		}
+	}
+	return sockets, nil
+}
+
+func (c *MockClient) ReadConfig(ctx context.Context, name string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.Configs[name]
+	if !ok {
+		return nil, fmt.Errorf("open %s: no such file or directory", ConfigPath(name))
+	}
+	return data, nil
+}
+
+func (c *MockClient) WriteConfig(ctx context.Context, name string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Configs[name] = data
+	return nil
+}
+
+func (c *MockClient) SyncConfig(ctx context.Context, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, iface := range c.Interfaces {
+		if iface.Name == name {
+			if iface.Status != InterfaceUp {
+				return fmt.Errorf("Unable to modify interface: No such device")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("interface not found")
+}
+
+func (c *MockClient) SetPrivateKey(ctx context.Context, name string, privateKey string) error {
+	pub, err := PublicKey(privateKey)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, iface := range c.Interfaces {
+		if iface.Name == name {
+			if iface.Status != InterfaceUp {
+				return fmt.Errorf("Unable to modify interface: No such device")
+			}
+			c.Interfaces[i].PublicKey = pub
+			return nil
+		}
+	}
+	return fmt.Errorf("interface not found")
+}
@@ -0,0 +1,151 @@
+package wg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortInspector is implemented by clients that can list the UDP sockets
+// bound on the machine, to find out what holds a ListenPort
+type PortInspector interface {
+	// UDPSockets returns every bound UDP socket. Only those on ports are
+	// looked up in /proc/*/fd to find their process, which is slow.
+	UDPSockets(ctx context.Context, ports []int) ([]UDPSocket, error)
+}
+
+// UDPSocket is a bound UDP socket as listed in /proc/net/udp and udp6.
+// Sockets opened by the kernel, such as those of WireGuard itself, have
+// inode 0 and no process.
+type UDPSocket struct {
+	Port    int    `json:"port"`
+	Inode   uint64 `json:"inode"`
+	PID     int    `json:"pid,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// Owner names what holds the socket
+func (s UDPSocket) Owner() string {
+	switch {
+	case s.Command != "":
+		return fmt.Sprintf("%s (pid %d)", s.Command, s.PID)
+	case s.Inode == 0:
+		return "the kernel"
+	}
+	return "another program"
+}
+
+// ParseProcNetUDP reads the socket tables of /proc/net/udp and udp6,
+// whose lines look like
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//	0: 00000000:CA6C 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 0
+//
+// Both tables may be concatenated; header lines are skipped.
+func ParseProcNetUDP(data []byte) []UDPSocket {
+	var sockets []UDPSocket
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseUint(hexPort, 16, 16)
+		if err != nil || port == 0 {
+			continue
+		}
+		inode, _ := strconv.ParseUint(fields[9], 10, 64)
+		sockets = append(sockets, UDPSocket{Port: int(port), Inode: inode})
+	}
+	return sockets
+}
+
+// ParseSocketOwners reads `ls -l /proc/[0-9]*/fd` into the pid holding
+// each socket inode. The listing has a "/proc/812/fd:" line before the
+// descriptors of each process, which end in "-> socket:[42954]".
+func ParseSocketOwners(data []byte) map[uint64]int {
+	owners := make(map[uint64]int)
+	pid := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if dir, ok := strings.CutSuffix(line, "/fd:"); ok {
+			pid, _ = strconv.Atoi(strings.TrimPrefix(dir, "/proc/"))
+			continue
+		}
+		_, target, ok := strings.Cut(line, " -> socket:[")
+		if !ok || pid == 0 {
+			continue
+		}
+		if inode, err := strconv.ParseUint(strings.TrimSuffix(target, "]"), 10, 64); err == nil {
+			owners[inode] = pid
+		}
+	}
+	return owners
+}
+
+// ListenPort is the port an interface uses: the one it runs on, or the
+// one its config asks for. Zero means a random one.
+func ListenPort(iface Interface, cfg *Config) int {
+	if iface.Status == InterfaceUp && iface.ListenPort > 0 {
+		return iface.ListenPort
+	}
+	if cfg == nil || cfg.Interface == nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(cfg.Interface.Get("ListenPort"))
+	return port
+}
+
+// PortConflicts says, by interface name, why an interface that is down
+// could not bind its ListenPort: another interface has it, or some other
+// socket does. A running interface holds its own port, so it only shows
+// up as what the others conflict with.
+func PortConflicts(ifaces []Interface, configs map[string]*Config, sockets []UDPSocket) map[string]string {
+	users := make(map[int][]Interface)
+	for _, iface := range ifaces {
+		if port := ListenPort(iface, configs[iface.Name]); port > 0 {
+			users[port] = append(users[port], iface)
+		}
+	}
+	conflicts := make(map[string]string)
+	for port, list := range users {
+		var up, down []string
+		for _, iface := range list {
+			if iface.Status == InterfaceUp {
+				up = append(up, iface.Name)
+			} else {
+				down = append(down, iface.Name)
+			}
+		}
+		for _, name := range down {
+			switch {
+			case len(up) > 0:
+				conflicts[name] = fmt.Sprintf("port %d is used by %s", port, up[0])
+			case len(down) > 1:
+				var others []string
+				for _, other := range down {
+					if other != name {
+						others = append(others, other)
+					}
+				}
+				conflicts[name] = fmt.Sprintf("port %d is shared with %s", port, strings.Join(others, ", "))
+			default:
+				for _, s := range sockets {
+					if s.Port == port {
+						conflicts[name] = fmt.Sprintf("port %d is in use by %s", port, s.Owner())
+						break
+					}
+				}
+			}
+		}
+	}
+	return conflicts
+}
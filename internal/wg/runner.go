@@ -0,0 +1,174 @@
+package wg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Runner runs commands on the machine whose interfaces are managed.
+// LinuxClient does everything through one, so the same code drives the
+// local machine, a remote one over SSH, or a fake in tests.
+type Runner interface {
+	// Run runs name with args and stops it when ctx is done. stdin,
+	// stdout and stderr may be nil.
+	Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error
+}
+
+// RunnerFunc lets a plain function act as a Runner, which is all a fake
+// needs to be
+type RunnerFunc func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error
+
+func (f RunnerFunc) Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	return f(ctx, stdin, stdout, stderr, name, args...)
+}
+
+// killGrace is how long a command may take to exit after SIGTERM
+const killGrace = 5 * time.Second
+
+// LocalRunner runs commands on this machine
+type LocalRunner struct{}
+
+func (LocalRunner) Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	// wg-quick is a shell script whose children (resolvconf, PostUp
+	// hooks) are what usually hangs, so stop the whole process group
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = killGrace
+	return cmd.Run()
+}
+
+// SSHRunner runs commands on another machine with the ssh binary, so the
+// user's ~/.ssh/config, keys and agent apply as usual. Connections are
+// shared, since a refresh runs several commands. Cancelling a command
+// closes its session; the remote side sees its output go away.
+type SSHRunner struct {
+	// Host is anything ssh accepts, such as gw1 or admin@10.0.0.1
+	Host string
+	// Exec runs the ssh binary; nil is a LocalRunner
+	Exec Runner
+
+	mu sync.Mutex
+	// probed is set once we know whether commands need sudo
+	probed bool
+	sudo   bool
+	dir    string
+}
+
+func NewSSHRunner(host string) *SSHRunner {
+	return &SSHRunner{Host: host}
+}
+
+func (r *SSHRunner) Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	sudo, err := r.setup(ctx)
+	if err != nil {
+		return err
+	}
+	// ssh hands the remote shell one string, so every word is quoted
+	words := []string{shellQuote(name)}
+	for _, a := range args {
+		words = append(words, shellQuote(a))
+	}
+	if sudo {
+		words = append([]string{"sudo", "-n"}, words...)
+	}
+	return r.ssh(ctx, stdin, stdout, stderr, strings.Join(words, " "))
+}
+
+// SessionClient returns the client address of the connection as the host
+// sees it. It runs without sudo, which would clear SSH_CONNECTION.
+func (r *SSHRunner) SessionClient(ctx context.Context) (netip.Addr, bool) {
+	if _, err := r.setup(ctx); err != nil {
+		return netip.Addr{}, false
+	}
+	var out bytes.Buffer
+	if err := r.ssh(ctx, nil, &out, nil, `echo "$SSH_CONNECTION"`); err != nil {
+		return netip.Addr{}, false
+	}
+	return sshClient(strings.TrimSpace(out.String()))
+}
+
+// ssh runs a command line on the host
+func (r *SSHRunner) ssh(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, command string) error {
+	var errOut bytes.Buffer
+	var w io.Writer = &errOut
+	if stderr != nil {
+		w = io.MultiWriter(&errOut, stderr)
+	}
+	err := r.exec().Run(ctx, stdin, stdout, w, "ssh", r.options(r.Host, "--", command)...)
+	// ssh itself exits with 255, the command never ran
+	if exit, ok := err.(interface{ ExitCode() int }); ok && exit.ExitCode() == 255 && ctx.Err() == nil {
+		return fmt.Errorf("ssh %s: %s", r.Host, lastLine(errOut.String()))
+	}
+	return err
+}
+
+func (r *SSHRunner) exec() Runner {
+	if r.Exec == nil {
+		return LocalRunner{}
+	}
+	return r.Exec
+}
+
+// setup opens the shared connection and finds out whether commands need
+// sudo, which they do unless we log in as root. A probe that fails, say
+// while the host is unreachable, is tried again by the next command.
+func (r *SSHRunner) setup(ctx context.Context) (sudo bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.probed {
+		return r.sudo, nil
+	}
+	if r.dir == "" {
+		if dir, err := os.MkdirTemp("", "wireguard-tui-ssh-"); err == nil {
+			r.dir = dir
+		}
+	}
+	var out bytes.Buffer
+	if err := r.ssh(ctx, nil, &out, nil, "id -u"); err != nil {
+		return false, err
+	}
+	r.sudo = strings.TrimSpace(out.String()) != "0"
+	r.probed = true
+	return r.sudo, nil
+}
+
+func (r *SSHRunner) options(args ...string) []string {
+	opts := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10"}
+	if r.dir != "" {
+		opts = append(opts,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPath="+filepath.Join(r.dir, "%C"),
+			"-o", "ControlPersist=60")
+	}
+	return append(opts, args...)
+}
+
+// Close ends the shared connection
+func (r *SSHRunner) Close() error {
+	if r.dir == "" {
+		return nil
+	}
+	r.exec().Run(context.Background(), nil, nil, nil, "ssh", r.options("-O", "exit", r.Host)...)
+	return os.RemoveAll(r.dir)
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=+:,@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
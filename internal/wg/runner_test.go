@@ -0,0 +1,159 @@
+package wg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeSSH stands in for the ssh binary. It records the remote command
+// line of each call and answers `id -u` with uid, or fails it while down
+// is set. SSH_CONNECTION is conn, and routes maps addresses to the
+// interface `ip route get` names for them.
+type fakeSSH struct {
+	uid      string
+	down     bool
+	conn     string
+	routes   map[string]string
+	commands []string
+}
+
+func (f *fakeSSH) Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	if name != "ssh" {
+		return fmt.Errorf("ran %s instead of ssh", name)
+	}
+	// The remote command line is what follows "--"
+	command := ""
+	for i, a := range args {
+		if a == "--" && i+1 < len(args) {
+			command = args[i+1]
+		}
+	}
+	if command == "" {
+		return nil
+	}
+	f.commands = append(f.commands, command)
+	if command == "id -u" {
+		if f.down {
+			io.WriteString(stderr, "ssh: connect to host gw1 port 22: Connection refused\n")
+			return exitStatus(255)
+		}
+		io.WriteString(stdout, f.uid+"\n")
+	}
+	if command == `echo "$SSH_CONNECTION"` {
+		io.WriteString(stdout, f.conn+"\n")
+	}
+	if dst, ok := strings.CutPrefix(strings.TrimPrefix(command, "sudo -n "), "ip route get "); ok {
+		if dev := f.routes[dst]; dev != "" {
+			fmt.Fprintf(stdout, "%s via 192.0.2.1 dev %s src 192.0.2.10 uid 0\n", dst, dev)
+		} else {
+			fmt.Fprintf(stdout, "%s via 192.0.2.1 dev eth0 src 192.0.2.10 uid 0\n", dst)
+		}
+	}
+	return nil
+}
+
+// exitStatus is how a command that ran and failed ends, like *exec.ExitError
+type exitStatus int
+
+func (e exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitStatus) ExitCode() int { return int(e) }
+
+func newFakeSSHRunner(t *testing.T, f *fakeSSH) *SSHRunner {
+	r := NewSSHRunner("gw1")
+	r.Exec = f
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"wg0", "wg0"},
+		{"/etc/wireguard/wg0.conf", "/etc/wireguard/wg0.conf"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"$(reboot)", "'$(reboot)'"},
+		{"it's", `'it'\''s'`},
+		{"a;b", "'a;b'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSSHRunnerQuotesEveryWord(t *testing.T) {
+	f := &fakeSSH{uid: "0"}
+	r := newFakeSSHRunner(t, f)
+	if err := r.Run(context.Background(), nil, nil, nil, "sh", "-c", `kill -TERM -"$1"`, "sh", "42"); err != nil {
+		t.Fatal(err)
+	}
+	want := `sh -c 'kill -TERM -"$1"' sh 42`
+	if got := f.commands[len(f.commands)-1]; got != want {
+		t.Errorf("ran %s, want %s", got, want)
+	}
+}
+
+func TestSSHRunnerSudo(t *testing.T) {
+	for _, tt := range []struct {
+		uid  string
+		want string
+	}{
+		{"0", "wg show wg0"},
+		{"1000", "sudo -n wg show wg0"},
+	} {
+		f := &fakeSSH{uid: tt.uid}
+		r := newFakeSSHRunner(t, f)
+		for range 2 {
+			if err := r.Run(context.Background(), nil, nil, nil, "wg", "show", "wg0"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := []string{"id -u", tt.want, tt.want}
+		if strings.Join(f.commands, "\n") != strings.Join(want, "\n") {
+			t.Errorf("uid %s: ran %q, want %q", tt.uid, f.commands, want)
+		}
+	}
+}
+
+func TestSSHRunnerProbesAgainAfterFailure(t *testing.T) {
+	f := &fakeSSH{uid: "1000", down: true}
+	r := newFakeSSHRunner(t, f)
+	err := r.Run(context.Background(), nil, nil, nil, "wg", "show")
+	if err == nil || !strings.Contains(err.Error(), "Connection refused") {
+		t.Fatalf("got %v, want the ssh error", err)
+	}
+
+	f.down = false
+	if err := r.Run(context.Background(), nil, nil, nil, "wg", "show"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.commands[len(f.commands)-1]; got != "sudo -n wg show" {
+		t.Errorf("ran %s after the host came back, want it with sudo", got)
+	}
+}
+
+func TestLockoutRisksOverSSH(t *testing.T) {
+	// We reach gw1 from 198.51.100.9, which gw1 routes through wg0
+	f := &fakeSSH{uid: "1000", conn: "198.51.100.9 50022 192.0.2.10 22", routes: map[string]string{"198.51.100.9": "wg0"}}
+	c := &LinuxClient{Runner: newFakeSSHRunner(t, f)}
+
+	risks := LockoutRisks(context.Background(), c, "wg0")
+	if len(risks) != 1 || !strings.Contains(risks[0], "198.51.100.9") {
+		t.Errorf("got risks %q, want the SSH connection from 198.51.100.9", risks)
+	}
+	if risks := LockoutRisks(context.Background(), c, "wg1"); len(risks) != 0 {
+		t.Errorf("got risks %q for wg1, want none", risks)
+	}
+	// sudo would have cleared SSH_CONNECTION
+	for _, cmd := range f.commands {
+		if strings.Contains(cmd, "SSH_CONNECTION") && strings.HasPrefix(cmd, "sudo") {
+			t.Errorf("ran %s", cmd)
+		}
+	}
+}